@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/stats"
+	"github.com/gorilla/mux"
+)
+
+// adminPaths are the prefixes of management routes, which are served on
+// -admin.addr instead of -http.addr when it's set
+var adminPaths = []string{"/admin/", "/metrics", "/debug/"}
+
+func isAdmin(path string) bool {
+	for _, prefix := range adminPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unprotectedHandler rejects requests to management routes with 403 when
+// they would be served on the api listener without a token
+func unprotectedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"error":"set -admin.token or -admin.addr to manage the service"}` + "\n"))
+	})
+}
+
+// errAdminOnly is returned by the management fields of GraphQL when there's
+// no admin token to check, words are then managed by -admin.addr only
+var errAdminOnly = errors.New("words are managed by the admin api only")
+
+// errUnauthorized is returned by the management fields of GraphQL to
+// requests without the admin token
+var errUnauthorized = errors.New("unauthorized")
+
+type authorizationKey struct{}
+
+// authorizationHandler carries the Authorization header of requests in their
+// context, for the endpoints checking the admin token
+func authorizationHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), authorizationKey{}, r.Header.Get("Authorization"))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// adminAuthMiddleware rejects requests whose context doesn't carry the
+// bearer token with errUnauthorized, like adminAuthHandler does with 401
+func adminAuthMiddleware(token string) endpoint.Middleware {
+	expected := []byte("Bearer " + token)
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			authorization, _ := ctx.Value(authorizationKey{}).(string)
+			if subtle.ConstantTimeCompare([]byte(authorization), expected) != 1 {
+				return nil, errUnauthorized
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// adminOnlyMiddleware rejects every request with errAdminOnly
+func adminOnlyMiddleware() endpoint.Middleware {
+	return func(endpoint.Endpoint) endpoint.Endpoint {
+		return func(context.Context, interface{}) (interface{}, error) {
+			return nil, errAdminOnly
+		}
+	}
+}
+
+// readToken returns the token held by file, empty if file is
+func readToken(file string) (string, error) {
+	if len(file) == 0 {
+		return "", nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(data)), nil
+}
+
+// adminAuthHandler rejects requests without the bearer token with 401,
+// every request passes if token is empty
+func adminAuthHandler(next http.Handler, token string) http.Handler {
+	if len(token) == 0 {
+		return next
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="wego admin"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"unauthorized"}` + "\n"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleDebug serves the profiles of net/http/pprof at /debug/pprof/ of r
+func handleDebug(r *mux.Router, token string) {
+	r.Handle("/debug/pprof/cmdline", adminAuthHandler(http.HandlerFunc(pprof.Cmdline), token))
+	r.Handle("/debug/pprof/profile", adminAuthHandler(http.HandlerFunc(pprof.Profile), token))
+	r.Handle("/debug/pprof/symbol", adminAuthHandler(http.HandlerFunc(pprof.Symbol), token))
+	r.Handle("/debug/pprof/trace", adminAuthHandler(http.HandlerFunc(pprof.Trace), token))
+	r.PathPrefix("/debug/pprof/").Handler(adminAuthHandler(http.HandlerFunc(pprof.Index), token))
+}
+
+// unprotected tells if the management routes would be served on the api
+// listener without a token, anyone reaching the api could then read and
+// change the dictionaries, which is refused unless -admin.insecure is set
+func unprotected(c *config, token string) bool {
+	return len(c.adminAddr) == 0 && len(token) == 0
+}
+
+// manageMiddleware restricts the word management of GraphQL like the
+// management routes are: it needs the admin token if there's one, and is
+// refused without it unless -admin.insecure is set with no -admin.addr
+func manageMiddleware(c *config, token string) endpoint.Middleware {
+	switch {
+	case len(token) > 0:
+		return adminAuthMiddleware(token)
+	case unprotected(c, token) && c.adminInsecure:
+		return func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+	default:
+		return adminOnlyMiddleware()
+	}
+}
+
+// adminRoutes returns the management routes, base explains verdicts and t
+// holds the knobs of /admin/runtime
+func adminRoutes(c *config, base textService, e endpoints, collector *stats.Collector, t tuning) []route {
+	statsHandler := httptransport.NewServer(
+		makeStatsEndpoint(collector),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			n := 20
+			if s := r.FormValue("n"); len(s) > 0 {
+				var err error
+				if n, err = strconv.Atoi(s); err != nil {
+					return nil, badRequestError{err}
+				}
+			}
+			return statsRequest{n}, nil
+		},
+		encodeResponse,
+	)
+
+	metricsHandler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		collector.WritePrometheus(w)
+	})
+
+	unusedHandler := httptransport.NewServer(
+		makeUnusedEndpoint(collector),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			window := c.unusedWindow
+			if s := r.FormValue("window"); len(s) > 0 {
+				var err error
+				if window, err = time.ParseDuration(s); err != nil {
+					return nil, badRequestError{err}
+				}
+			}
+			return unusedRequest{window}, nil
+		},
+		encodeResponse,
+	)
+
+	testHandler := httptransport.NewServer(
+		e.limit(makeTestEndpoint(dict.ParseNormalizers(c.dictNormalizers), c.dictOverlap, detectorNames(c.dictDetectors))),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			if err := r.ParseForm(); err != nil {
+				return nil, badRequestError{err}
+			}
+			if len(r.Form["word"]) == 0 {
+				return nil, badRequestError{errors.New("word is required")}
+			}
+			return testRequest{r.Form.Get("message"), r.Form["word"]}, nil
+		},
+		encodeResponse,
+	)
+
+	explainHandler := httptransport.NewServer(
+		e.limit(makeExplainEndpoint(base)),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			return explainRequest{r.FormValue("message")}, nil
+		},
+		encodeResponse,
+	)
+
+	diffHandler := httptransport.NewServer(
+		makeDiffEndpoint(),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			from, to := r.FormValue("from"), r.FormValue("to")
+			if len(from) == 0 {
+				from = "previous"
+			}
+			if len(to) == 0 {
+				to = dict.Stable
+			}
+			return diffRequest{from, to}, nil
+		},
+		encodeResponse,
+	)
+	snapshotsHandler := httptransport.NewServer(
+		makeSnapshotsEndpoint(),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			return nil, nil
+		},
+		encodeResponse,
+	)
+
+	decodeWordRequest := func(enabled bool) httptransport.DecodeRequestFunc {
+		return func(_ context.Context, r *http.Request) (interface{}, error) {
+			word := r.FormValue("word")
+			if len(word) == 0 {
+				return nil, badRequestError{errors.New("word is required")}
+			}
+			return wordRequest{word, enabled}, nil
+		}
+	}
+	enableHandler := httptransport.NewServer(
+		makeWordEndpoint(),
+		decodeWordRequest(true),
+		encodeResponse,
+	)
+	disableHandler := httptransport.NewServer(
+		makeWordEndpoint(),
+		decodeWordRequest(false),
+		encodeResponse,
+	)
+	decodeSourceRequest := func(enabled bool) httptransport.DecodeRequestFunc {
+		return func(_ context.Context, r *http.Request) (interface{}, error) {
+			source := r.FormValue("file")
+			if len(source) == 0 {
+				return nil, badRequestError{errors.New("file is required")}
+			}
+			return sourceRequest{source, enabled}, nil
+		}
+	}
+	enableFileHandler := httptransport.NewServer(
+		makeSourceEndpoint(),
+		decodeSourceRequest(true),
+		encodeResponse,
+	)
+	disableFileHandler := httptransport.NewServer(
+		makeSourceEndpoint(),
+		decodeSourceRequest(false),
+		encodeResponse,
+	)
+	dictHandler := httptransport.NewServer(
+		makeDictEndpoint(c.dictRefresh),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			return nil, nil
+		},
+		encodeResponse,
+	)
+	entriesHandler := httptransport.NewServer(
+		makeEntriesEndpoint(),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			return nil, nil
+		},
+		encodeResponse,
+	)
+	disabledHandler := httptransport.NewServer(
+		makeDisabledEndpoint(),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			return nil, nil
+		},
+		encodeResponse,
+	)
+	pushHandler := maxBytesHandler(c.adminMaxBody, httptransport.NewServer(
+		makePushEndpoint(),
+		decodePushRequest,
+		encodeResponse,
+	))
+	runtimeHandler := httptransport.NewServer(
+		makeRuntimeEndpoint(t),
+		decodeRuntimeRequest,
+		encodeResponse,
+	)
+	bulkHandler := maxBytesHandler(c.adminMaxBody, httptransport.NewServer(
+		makeBulkEndpoint(),
+		decodeBulkRequest,
+		encodeResponse,
+	))
+
+	word := apiParam{Name: "word", Description: "Word of the dictionary", Required: true}
+	file := apiParam{Name: "file", Description: "File name without directory and extension, like ads for /etc/wego/ads.txt", Required: true}
+	return []route{
+		{"GET", "/admin/stats", statsHandler, apiDoc{
+			Summary:   "Top matched words and recent hit rates",
+			Params:    []apiParam{{Name: "n", Description: "Number of top words, 20 by default", Type: "integer"}},
+			Responses: []interface{}{stats.Snapshot{}},
+		}},
+		{"GET", "/metrics", metricsHandler, apiDoc{
+			Summary: "Latency histograms by method and text length, in the Prometheus text format",
+		}},
+		{"GET", "/admin/stats/unused", unusedHandler, apiDoc{
+			Summary:   "Words of the dictionary never matched in a window",
+			Params:    []apiParam{{Name: "window", Description: "Duration like 168h"}},
+			Responses: []interface{}{stats.UnusedReport{}},
+		}},
+		{"POST", "/admin/words/enable", enableHandler, apiDoc{
+			Summary:   "Enable a disabled word",
+			Params:    []apiParam{word},
+			Responses: []interface{}{wordResponse{}},
+		}},
+		{"POST", "/admin/words/disable", disableHandler, apiDoc{
+			Summary:   "Disable a word until it's enabled again",
+			Params:    []apiParam{word},
+			Responses: []interface{}{wordResponse{}},
+		}},
+		{"GET", "/admin/words/disabled", disabledHandler, apiDoc{
+			Summary:   "List disabled words",
+			Responses: []interface{}{disabledResponse{}},
+		}},
+		{"GET", "/admin/runtime", runtimeHandler, apiDoc{
+			Summary:   "Runtime knobs",
+			Responses: []interface{}{runtimeResponse{}},
+		}},
+		{"POST", "/admin/runtime", runtimeHandler, apiDoc{
+			Summary: "Change runtime knobs while serving, the ones not given are kept",
+			Params: []apiParam{
+				{Name: "gc_percent", Description: "GOGC, negative disables the garbage collector", Type: "integer"},
+				{Name: "workers", Description: "Max number of texts matched at the same time, unlimited if 0", Type: "integer"},
+				{Name: "queue", Description: "Max number of requests waiting for a worker", Type: "integer"},
+				{Name: "cache_size", Description: "Number of texts whose matches are cached, disabled if 0", Type: "integer"},
+				{Name: "log_level", Description: "Log level", Enum: logLevels},
+			},
+			Responses: []interface{}{runtimeResponse{}},
+		}},
+		{"POST", "/admin/words/bulk", bulkHandler, apiDoc{
+			Summary: "Add words uploaded as the file field of a multipart form or as a json array, nothing is added if a line is invalid",
+			Params: []apiParam{{
+				Name:        "dry_run",
+				Description: "Only report what would be added",
+				Type:        "boolean",
+			}},
+			Responses: []interface{}{bulkResponse{}},
+			Errors: map[int]string{
+				http.StatusUnprocessableEntity:   "Invalid or conflicting lines, see lines",
+				http.StatusRequestEntityTooLarge: "The body is larger than -admin.max-body",
+			},
+		}},
+		{"GET", "/admin/dict", dictHandler, apiDoc{
+			Summary:   "Files the stable dictionary is loaded from, with their number of words and whether they are enabled",
+			Responses: []interface{}{dictResponse{}},
+		}},
+		{"GET", "/admin/dict/entries", entriesHandler, apiDoc{
+			Summary:   "Entries of the stable dictionary, as taken by /admin/dict/push",
+			Responses: []interface{}{[]dict.Entry{}},
+		}},
+		{"POST", "/admin/dict/files/enable", enableFileHandler, apiDoc{
+			Summary:   "Enable the words of a disabled file",
+			Params:    []apiParam{file},
+			Responses: []interface{}{sourceResponse{}},
+		}},
+		{"POST", "/admin/dict/files/disable", disableFileHandler, apiDoc{
+			Summary:   "Disable the words of a file until it's enabled again, reloading keeps it disabled",
+			Params:    []apiParam{file},
+			Responses: []interface{}{sourceResponse{}},
+		}},
+		{"GET", "/admin/dict/snapshots", snapshotsHandler, apiDoc{
+			Summary:   "Dictionaries loaded recently, oldest first",
+			Responses: []interface{}{snapshotsResponse{}},
+		}},
+		{"GET", "/admin/dict/diff", diffHandler, apiDoc{
+			Summary: "Words added, removed and changed between two loaded dictionaries",
+			Params: []apiParam{
+				{Name: "from", Description: "Revision, stable, canary or previous (default), the stable dictionary loaded before"},
+				{Name: "to", Description: "Revision, stable (default), canary or previous"},
+			},
+			Responses: []interface{}{dict.DiffResult{}},
+		}},
+		{"POST", "/admin/dict/push", pushHandler, apiDoc{
+			Summary:   "Replace the stable dictionary with a json array of entries, as pushed by wego sync",
+			Responses: []interface{}{pushResponse{}},
+			Errors: map[int]string{
+				http.StatusRequestEntityTooLarge: "The body is larger than -admin.max-body",
+			},
+		}},
+		{"POST", "/admin/test", testHandler, apiDoc{
+			Summary: "Match a sample text against candidate words without changing the dictionary",
+			Params: []apiParam{messageParam, {
+				Name:        "word",
+				Description: "Candidate words, repeated",
+				Type:        "array",
+				Required:    true,
+			}},
+			Responses: []interface{}{testResponse{}},
+		}},
+		{"POST", "/admin/explain", explainHandler, apiDoc{
+			Summary:   "Explain why a message passed or not: normalizations applied, hits and words found but rejected with the reason",
+			Params:    []apiParam{messageParam},
+			Responses: []interface{}{explainResponse{}},
+		}},
+	}
+}
+
+// routers splits routes into the api router and the admin one served on
+// -admin.addr: management routes need the admin token and move to the admin
+// router when -admin.addr is set, health checks are on both
+func routers(c *config, routes []route, token string, logger log.Logger) (api, admin *mux.Router, err error) {
+	unprotected := unprotected(c, token)
+	if unprotected && c.adminInsecure {
+		logger.Log("component", "http", "warning", "management routes are served to anyone, -admin.insecure is set without -admin.token nor -admin.addr")
+	} else if unprotected {
+		logger.Log("component", "http", "warning", "management routes are disabled, set -admin.token or -admin.addr")
+	}
+
+	api, admin = mux.NewRouter(), mux.NewRouter()
+	var public, management []route
+	for _, rt := range routes {
+		if !isAdmin(rt.path) {
+			public = append(public, rt)
+			if rt.path == "/healthz" || rt.path == "/readyz" {
+				management = append(management, rt)
+			}
+			continue
+		}
+		rt.handler = adminAuthHandler(rt.handler, token)
+		if unprotected && !c.adminInsecure {
+			rt.handler = unprotectedHandler()
+		}
+		if len(c.adminAddr) > 0 {
+			management = append(management, rt)
+		} else {
+			public = append(public, rt)
+		}
+	}
+	for _, served := range []struct {
+		router *mux.Router
+		routes []route
+	}{{api, public}, {admin, management}} {
+		for _, rt := range served.routes {
+			served.router.Handle(rt.path, rt.handler).Methods(rt.method)
+		}
+		spec, err := json.Marshal(openAPI(served.routes))
+		if err != nil {
+			return nil, nil, err
+		}
+		served.router.HandleFunc("/openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(spec)
+		}).Methods("GET")
+	}
+	if len(c.adminAddr) > 0 {
+		handleDebug(admin, token)
+	}
+	if c.httpSwaggerUI {
+		api.HandleFunc("/docs", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, swaggerUI)
+		}).Methods("GET")
+	}
+	return api, admin, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/stats"
+)
+
+func writeToken(t *testing.T, token string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "token.txt")
+	if err := ioutil.WriteFile(file, []byte(token+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestAdminRoutes(t *testing.T) {
+	token := writeToken(t, "secret")
+	tests := []struct {
+		name   string
+		args   []string
+		admin  bool // the request goes to the admin listener
+		method string
+		path   string
+		auth   string
+		want   int
+	}{
+		{"unprotected stats", nil, false, "GET", "/admin/stats", "", http.StatusForbidden},
+		{"unprotected metrics", nil, false, "GET", "/metrics", "", http.StatusForbidden},
+		{"unprotected entries", nil, false, "GET", "/admin/dict/entries", "", http.StatusForbidden},
+		{"unprotected disable", nil, false, "POST", "/admin/words/disable?word=bad", "", http.StatusForbidden},
+		{"unprotected explain", nil, false, "POST", "/admin/explain?message=bad", "", http.StatusForbidden},
+		{"unprotected health", nil, false, "GET", "/healthz", "", http.StatusOK},
+		{"insecure stats", []string{"-admin.insecure"}, false, "GET", "/admin/stats", "", http.StatusOK},
+		{"token missing", []string{"-admin.token", token}, false, "GET", "/admin/stats", "", http.StatusUnauthorized},
+		{"token wrong", []string{"-admin.token", token}, false, "GET", "/metrics", "Bearer nope", http.StatusUnauthorized},
+		{"token", []string{"-admin.token", token}, false, "GET", "/admin/stats", "Bearer secret", http.StatusOK},
+		{"admin addr on api", []string{"-admin.addr", "127.0.0.1:0"}, false, "GET", "/admin/stats", "", http.StatusNotFound},
+		{"admin addr", []string{"-admin.addr", "127.0.0.1:0"}, true, "GET", "/admin/stats", "", http.StatusOK},
+		{"admin addr health", []string{"-admin.addr", "127.0.0.1:0"}, true, "GET", "/healthz", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.args...)
+			handler := s.api
+			if tt.admin {
+				handler = s.admin
+			}
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			if len(tt.auth) > 0 {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestGraphQLManagement(t *testing.T) {
+	t.Cleanup(func() { dict.Enable("bad") })
+	token := writeToken(t, "secret")
+	tests := []struct {
+		name string
+		args []string
+		auth string
+		want string
+	}{
+		{"unprotected", nil, "", errAdminOnly.Error()},
+		{"insecure", []string{"-admin.insecure"}, "", `{"word":"bad","enabled":false}`},
+		{"admin addr", []string{"-admin.addr", "127.0.0.1:0"}, "", errAdminOnly.Error()},
+		{"token missing", []string{"-admin.token", token}, "", errUnauthorized.Error()},
+		{"token wrong", []string{"-admin.token", token}, "Bearer nope", errUnauthorized.Error()},
+		{"token", []string{"-admin.token", token}, "Bearer secret", `{"word":"bad","enabled":false}`},
+		{"token and admin addr", []string{"-admin.token", token, "-admin.addr", "127.0.0.1:0"}, "Bearer secret", `{"word":"bad","enabled":false}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.args...)
+			body := `{"query": "mutation { disableWord(word: \"bad\") { word enabled } }"}`
+			r := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			if len(tt.auth) > 0 {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			s.api.ServeHTTP(w, r)
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("body = %s, want %s", w.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnused(t *testing.T) {
+	loadTestDict(t, "bad", "worse")
+	s := newTestServer(t, "-admin.insecure")
+
+	r := httptest.NewRequest("POST", "/validate", strings.NewReader("message=so+bad"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.api.ServeHTTP(httptest.NewRecorder(), r)
+
+	w := httptest.NewRecorder()
+	s.api.ServeHTTP(w, httptest.NewRequest("GET", "/admin/stats/unused?window=1h", nil))
+	var report stats.UnusedReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Total != 2 || len(report.Unused) != 1 || report.Unused[0] != "worse" {
+		t.Errorf("unused = %v of %d, want [worse] of 2", report.Unused, report.Total)
+	}
+}
+
+func TestLatencyMetrics(t *testing.T) {
+	loadTestDict(t, "bad")
+	s := newTestServer(t, "-admin.insecure")
+	for _, message := range []string{"bad", strings.Repeat("好", 100), strings.Repeat("x", 5000)} {
+		r := httptest.NewRequest("POST", "/check", strings.NewReader("message="+message))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		s.api.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	w := httptest.NewRecorder()
+	s.api.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, want := range []string{
+		`wego_request_duration_seconds_count{method="check",length="0-64"} 1`,
+		`wego_request_duration_seconds_count{method="check",length="64-512"} 1`,
+		`wego_request_duration_seconds_count{method="check",length="512-4k"} 0`,
+		`wego_request_duration_seconds_count{method="check",length="4k+"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("/metrics lacks %s", want)
+		}
+	}
+}
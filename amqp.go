@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// amqpPrefetch is the number of requests delivered to a connection before
+// it acknowledges them
+const amqpPrefetch = 64
+
+// serveAMQP consumes the queues of subscribers, named after them prefixed
+// with prefix, on the RabbitMQ server at rawurl and replies to the reply_to
+// queue of requests with their correlation_id, reconnecting until stop is
+// closed. amqps urls connect with tls, trusting the authorities of the PEM
+// file ca or the system ones.
+func serveAMQP(rawurl, prefix, ca string, subscribers []mqSubscriber, tenants map[string]dict.Replacement, logger log.Logger, rec recoverer, stop <-chan struct{}) {
+	serveMQ("AMQP", func(stop <-chan struct{}) error {
+		return runAMQP(rawurl, prefix, ca, subscribers, tenants, logger, rec, stop)
+	}, logger, stop)
+}
+
+// runAMQP consumes requests until the connection fails, requests are
+// acknowledged once replied to, those in flight are delivered again to
+// another consumer if it fails
+func runAMQP(rawurl, prefix, ca string, subscribers []mqSubscriber, tenants map[string]dict.Replacement, logger log.Logger, rec recoverer, stop <-chan struct{}) error {
+	config := amqp.Config{Properties: amqp.Table{"connection_name": "wego"}}
+	if len(ca) > 0 {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return err
+		}
+		if config.TLSClientConfig, err = tlsConfig(u.Hostname(), ca); err != nil {
+			return err
+		}
+	}
+	conn, err := amqp.DialConfig(rawurl, config)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	if err := ch.Qos(amqpPrefetch, 0, false); err != nil {
+		return err
+	}
+	// the queues are declared before consuming any, requests may be
+	// delivered as soon as a consumer starts
+	for _, s := range subscribers {
+		if _, err := ch.QueueDeclare(prefix+"."+s.name, true, false, false, false, nil); err != nil {
+			return err
+		}
+	}
+	for _, s := range subscribers {
+		deliveries, err := ch.Consume(prefix+"."+s.name, "", false, false, false, false, nil)
+		if err != nil {
+			return err
+		}
+		go func(s mqSubscriber, deliveries <-chan amqp.Delivery) {
+			for d := range deliveries {
+				go serveAMQPDelivery(ch, s, d, tenants, rec)
+			}
+		}(s, deliveries)
+	}
+	logger.Log("transport", "AMQP", "addr", conn.RemoteAddr().String(), "tls", conn.ConnectionState().HandshakeComplete)
+
+	select {
+	case <-stop:
+		return nil
+	case err := <-closed:
+		if err == nil {
+			return errors.New("connection closed")
+		}
+		return err
+	}
+}
+
+// serveAMQPDelivery publishes the response of the endpoint of s to the
+// reply_to queue of the request d, through the default exchange, and
+// acknowledges it. Requests without reply_to are acknowledged and dropped.
+func serveAMQPDelivery(ch *amqp.Channel, s mqSubscriber, d amqp.Delivery, tenants map[string]dict.Replacement, rec recoverer) {
+	if len(d.ReplyTo) > 0 {
+		header := make(http.Header)
+		for key, value := range d.Headers {
+			if value, ok := value.(string); ok {
+				header.Set(key, value)
+			}
+		}
+		data := s.respond(mqContext(header, tenants), d.Body, rec)
+		err := ch.PublishWithContext(context.Background(), "", d.ReplyTo, false, false, amqp.Publishing{
+			ContentType:   "application/json",
+			CorrelationId: d.CorrelationId,
+			Body:          data,
+		})
+		if err != nil {
+			return // delivered again once the connection is closed
+		}
+	}
+	d.Ack(false)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/goofansu/wego/dict"
+)
+
+// batchRequest carries every "message" value of a batch call
+type batchRequest struct {
+	S []string `json:"messages"`
+}
+
+// batch responses come with the revisions of the dictionaries all their
+// messages are matched against, even if they are reloaded meanwhile
+type validateBatchResponse struct {
+	V              []bool `json:"result"`
+	Revision       string `json:"revision"`
+	CanaryRevision string `json:"canary_revision,omitempty"`
+}
+
+// filterBatchItem is a filtered message, or the reasons it was rejected for
+// in strict mode
+type filterBatchItem struct {
+	V       string     `json:"result"`
+	Error   string     `json:"error,omitempty"`
+	Reasons []dict.Hit `json:"reasons,omitempty"`
+}
+
+type filterBatchResponse struct {
+	V              []filterBatchItem `json:"result"`
+	Revision       string            `json:"revision"`
+	CanaryRevision string            `json:"canary_revision,omitempty"`
+}
+
+type detectBatchResponse struct {
+	V              [][]dict.Hit `json:"result"`
+	Revision       string       `json:"revision"`
+	CanaryRevision string       `json:"canary_revision,omitempty"`
+}
+
+// batchLimitMiddleware rejects batch requests of more than max messages with
+// badRequestError, unlimited if max <= 0
+func batchLimitMiddleware(max int) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if req, ok := request.(batchRequest); ok && max > 0 && len(req.S) > max {
+				return nil, badRequestError{fmt.Errorf("%d messages, batches are limited to %d", len(req.S), max)}
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+func makeValidateBatchEndpoint(svc TextService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ctx = dict.Pin(ctx)
+		req := request.(batchRequest)
+		v := make([]bool, len(req.S))
+		for i, s := range req.S {
+			valid, err := svc.Validate(ctx, s)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = valid
+		}
+		stable, canary := dict.Revisions(ctx)
+		return validateBatchResponse{v, stable, canary}, nil
+	}
+}
+
+func makeFilterBatchEndpoint(svc TextService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ctx = dict.Pin(ctx)
+		req := request.(batchRequest)
+		v := make([]filterBatchItem, len(req.S))
+		for i, s := range req.S {
+			filtered, err := svc.Filter(ctx, s)
+			if blocked, ok := err.(blockedError); ok {
+				v[i] = filterBatchItem{Error: blocked.Error(), Reasons: blocked.reasons}
+				continue
+			}
+			if err, ok := err.(invalidUTF8Error); ok {
+				v[i] = filterBatchItem{Error: err.Error()}
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			v[i] = filterBatchItem{V: filtered}
+		}
+		stable, canary := dict.Revisions(ctx)
+		return filterBatchResponse{v, stable, canary}, nil
+	}
+}
+
+func makeDetectBatchEndpoint(svc TextService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		ctx = dict.Pin(ctx)
+		req := request.(batchRequest)
+		v := make([][]dict.Hit, len(req.S))
+		for i, s := range req.S {
+			hits, err := svc.Detect(ctx, s)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = hits
+		}
+		stable, canary := dict.Revisions(ctx)
+		return detectBatchResponse{v, stable, canary}, nil
+	}
+}
+
+func decodeBatchRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, bodyError(err)
+	}
+	return batchRequest{r.Form["message"]}, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/goofansu/wego/dict"
+)
+
+// bulkRequest carries the words to add to the dictionary at once
+type bulkRequest struct {
+	Candidates []dict.Candidate
+	DryRun     bool
+}
+
+type bulkResponse struct {
+	Committed bool              `json:"committed"`
+	Added     int               `json:"added"`
+	Lines     []dict.ImportLine `json:"lines"`
+}
+
+// rejectedError is returned when nothing was added because of invalid or
+// conflicting lines, the report tells which ones
+type rejectedError struct {
+	lines []dict.ImportLine
+}
+
+func (e rejectedError) Error() string {
+	return "invalid or conflicting words, nothing was added"
+}
+
+func (e rejectedError) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+func (e rejectedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error string            `json:"error"`
+		Lines []dict.ImportLine `json:"lines"`
+	}{e.Error(), e.lines})
+}
+
+func makeBulkEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(bulkRequest)
+		lines, committed, err := dict.Add(req.Candidates, req.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, line := range lines {
+			if line.Status == dict.ImportOK {
+				added++
+			}
+		}
+		if !committed && !req.DryRun && rejected(lines) {
+			return nil, rejectedError{lines}
+		}
+		return bulkResponse{committed, added, lines}, nil
+	}
+}
+
+func rejected(lines []dict.ImportLine) bool {
+	for _, line := range lines {
+		if line.Status == dict.ImportInvalid || line.Status == dict.ImportConflict {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeBulkRequest reads a dictionary file uploaded as the "file" field of
+// a multipart form, in the format given by its name, or a json array of words
+// and entries
+func decodeBulkRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req bulkRequest
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		f, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, bodyError(err)
+		}
+		defer f.Close()
+		if req.Candidates, err = dict.ParseCandidates(header.Filename, f); err != nil {
+			return nil, badRequestError{err}
+		}
+	} else if req.Candidates, err = dict.ParseCandidates(".json", r.Body); err != nil {
+		return nil, bodyError(err)
+	}
+
+	if s := r.FormValue("dry_run"); len(s) > 0 {
+		if req.DryRun, err = strconv.ParseBool(s); err != nil {
+			return nil, badRequestError{err}
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	loadTestDict(t, "bad")
+	tests := []struct {
+		name     string
+		args     []string
+		message  string
+		code     int
+		valid    bool
+		filtered string
+	}{
+		{"clean", nil, "all good", http.StatusOK, true, "all good"},
+		{"hit", nil, "so bad", http.StatusOK, false, "so ***"},
+		{"strict clean", []string{"-filter.strict"}, "all good", http.StatusOK, true, "all good"},
+		{"strict hit", []string{"-filter.strict"}, "so bad", http.StatusUnprocessableEntity, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.args...)
+			form := url.Values{"message": {tt.message}}
+			r := httptest.NewRequest("POST", "/check", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			s.api.ServeHTTP(w, r)
+			if w.Code != tt.code {
+				t.Fatalf("POST /check %q = %d %s, want %d", tt.message, w.Code, strings.TrimSpace(w.Body.String()), tt.code)
+			}
+			if w.Code != http.StatusOK {
+				if !strings.Contains(w.Body.String(), `"reasons":[`) {
+					t.Errorf("blocked message answered %s, want reasons", strings.TrimSpace(w.Body.String()))
+				}
+				return
+			}
+			var resp checkResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Valid != tt.valid || resp.Filtered != tt.filtered {
+				t.Errorf("POST /check %q = %v %q, want %v %q", tt.message, resp.Valid, resp.Filtered, tt.valid, tt.filtered)
+			}
+		})
+	}
+
+	// a single pass answers like validate and filter
+	s := newTestServer(t)
+	ctx := context.Background()
+	for _, message := range []string{"all good", "so bad", "bad, bad"} {
+		checked, err := s.endpoints.check(ctx, checkRequest{S: message})
+		if err != nil {
+			t.Fatal(err)
+		}
+		valid, _ := s.endpoints.validate(ctx, validateRequest{S: message})
+		filtered, _ := s.endpoints.filter(ctx, filterRequest{S: message})
+		got := checked.(checkResponse)
+		if got.Valid != valid.(validateResponse).V || got.Filtered != filtered.(filterResponse).V {
+			t.Errorf("check %q = %+v, validate %+v, filter %+v", message, got, valid, filtered)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+// Package client is a Go client of the wego http api
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+)
+
+// Hit is a word found in a message, Rule is the stable id of the entry
+// and offsets are in bytes and runes
+type Hit struct {
+	Rule      string `json:"rule"`
+	Word      string `json:"word"`
+	Category  string `json:"category,omitempty"`
+	Severity  int    `json:"severity,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	RuneStart int    `json:"rune_start"`
+	RuneEnd   int    `json:"rune_end"`
+	Context   string `json:"context,omitempty"`
+}
+
+// FilterResult is a message of FilterBatch, Blocked is set instead of Text
+// when the message is rejected in strict mode and Err when it's rejected
+// otherwise, like a message which is not valid utf-8
+type FilterResult struct {
+	Text    string
+	Blocked *BlockedError
+	Err     *StatusError
+}
+
+// BlockedError is returned by Filter when the server runs in strict mode and
+// the message contains blocked words
+type BlockedError struct {
+	Reasons []Hit
+}
+
+func (e *BlockedError) Error() string {
+	return "message contains blocked words"
+}
+
+// StatusError is returned for unexpected responses of the server
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("wego: %d %s", e.Code, e.Message)
+}
+
+// Temporary tells if the request may succeed when retried
+func (e *StatusError) Temporary() bool {
+	return e.Code >= 500
+}
+
+// Client calls a wego server, it's safe for concurrent use
+type Client struct {
+	validate, filter, check, detect         endpoint.Endpoint
+	validateBatch, filterBatch, detectBatch endpoint.Endpoint
+}
+
+type options struct {
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	failures   int
+	cooldown   time.Duration
+}
+
+// Option configures a Client
+type Option func(*options)
+
+// HTTPClient sets the http client used for requests, by default a client
+// keeping up to 100 idle connections to the server is used
+func HTTPClient(c *http.Client) Option {
+	return func(o *options) { o.httpClient = c }
+}
+
+// Retry retries failed requests up to n times, waiting backoff before the
+// first retry and doubling it each time. Only network errors and 5xx
+// responses are retried.
+func Retry(n int, backoff time.Duration) Option {
+	return func(o *options) { o.retries, o.backoff = n, backoff }
+}
+
+// CircuitBreaker fails requests right away with ErrCircuitOpen for cooldown
+// after failures consecutive failed requests, 0 disables it
+func CircuitBreaker(failures int, cooldown time.Duration) Option {
+	return func(o *options) { o.failures, o.cooldown = failures, cooldown }
+}
+
+// New returns a client of the server at instance, like "http://localhost:8080".
+// Requests are retried twice and the circuit opens for 10 seconds after 5
+// consecutive failures unless configured otherwise.
+func New(instance string, opts ...Option) (*Client, error) {
+	if !strings.HasPrefix(instance, "http") {
+		instance = "http://" + instance
+	}
+	base, err := url.Parse(instance)
+	if err != nil {
+		return nil, err
+	}
+
+	o := options{
+		retries:  2,
+		backoff:  100 * time.Millisecond,
+		failures: 5,
+		cooldown: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = 100
+		o.httpClient = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	}
+
+	breaker := circuitBreaker(o.failures, o.cooldown)
+	makeEndpoint := func(path string, dec httptransport.DecodeResponseFunc) endpoint.Endpoint {
+		tgt := *base
+		tgt.Path = strings.TrimSuffix(tgt.Path, "/") + path
+		e := httptransport.NewClient("POST", &tgt, encodeRequest, dec,
+			httptransport.SetClient(o.httpClient)).Endpoint()
+		return breaker(retry(o.retries, o.backoff)(e))
+	}
+
+	return &Client{
+		validate:      makeEndpoint("/validate", decodeResponse(func() interface{} { return new(bool) })),
+		filter:        makeEndpoint("/filter", decodeResponse(func() interface{} { return new(string) })),
+		check:         makeEndpoint("/check", decodeCheckResponse),
+		detect:        makeEndpoint("/detect", decodeResponse(func() interface{} { return new([]Hit) })),
+		validateBatch: makeEndpoint("/validate/batch", decodeResponse(func() interface{} { return new([]bool) })),
+		filterBatch:   makeEndpoint("/filter/batch", decodeResponse(func() interface{} { return new([]filterItem) })),
+		detectBatch:   makeEndpoint("/detect/batch", decodeResponse(func() interface{} { return new([][]Hit) })),
+	}, nil
+}
+
+// Validate tells if message contains no words of the dictionary
+func (c *Client) Validate(ctx context.Context, message string) (bool, error) {
+	v, err := c.validate(ctx, url.Values{"message": {message}})
+	if err != nil {
+		return false, err
+	}
+	return *v.(*bool), nil
+}
+
+// Filter masks the words of the dictionary in message, a *BlockedError is
+// returned when the server rejects message in strict mode
+func (c *Client) Filter(ctx context.Context, message string) (string, error) {
+	v, err := c.filter(ctx, url.Values{"message": {message}})
+	if err != nil {
+		return "", err
+	}
+	return *v.(*string), nil
+}
+
+// Check is Validate and Filter in one request, message is matched once
+func (c *Client) Check(ctx context.Context, message string) (valid bool, filtered string, err error) {
+	v, err := c.check(ctx, url.Values{"message": {message}})
+	if err != nil {
+		return false, "", err
+	}
+	result := v.(*checkResult)
+	return result.Valid, result.Filtered, nil
+}
+
+// Detect returns the words of the dictionary found in message
+func (c *Client) Detect(ctx context.Context, message string) ([]Hit, error) {
+	v, err := c.detect(ctx, url.Values{"message": {message}})
+	if err != nil {
+		return nil, err
+	}
+	return *v.(*[]Hit), nil
+}
+
+// ValidateBatch is Validate for many messages in one request
+func (c *Client) ValidateBatch(ctx context.Context, messages []string) ([]bool, error) {
+	v, err := c.validateBatch(ctx, url.Values{"message": messages})
+	if err != nil {
+		return nil, err
+	}
+	return *v.(*[]bool), nil
+}
+
+// FilterBatch is Filter for many messages in one request
+func (c *Client) FilterBatch(ctx context.Context, messages []string) ([]FilterResult, error) {
+	v, err := c.filterBatch(ctx, url.Values{"message": messages})
+	if err != nil {
+		return nil, err
+	}
+
+	items := *v.(*[]filterItem)
+	results := make([]FilterResult, len(items))
+	for i, item := range items {
+		if len(item.Reasons) > 0 {
+			results[i].Blocked = &BlockedError{item.Reasons}
+			continue
+		}
+		if len(item.Error) > 0 {
+			results[i].Err = &StatusError{http.StatusBadRequest, item.Error}
+			continue
+		}
+		results[i].Text = item.Result
+	}
+	return results, nil
+}
+
+// DetectBatch is Detect for many messages in one request
+func (c *Client) DetectBatch(ctx context.Context, messages []string) ([][]Hit, error) {
+	v, err := c.detectBatch(ctx, url.Values{"message": messages})
+	if err != nil {
+		return nil, err
+	}
+	return *v.(*[][]Hit), nil
+}
+
+type filterItem struct {
+	Result  string `json:"result"`
+	Error   string `json:"error"`
+	Reasons []Hit  `json:"reasons"`
+}
+
+func encodeRequest(_ context.Context, r *http.Request, request interface{}) error {
+	body := request.(url.Values).Encode()
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Body = ioutil.NopCloser(strings.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(body)), nil
+	}
+	return nil
+}
+
+// decodeResponse decodes the "result" of a response into the value returned
+// by result
+func decodeResponse(result func() interface{}) httptransport.DecodeResponseFunc {
+	return func(_ context.Context, r *http.Response) (interface{}, error) {
+		if r.StatusCode != http.StatusOK {
+			return nil, decodeError(r)
+		}
+		v := result()
+		if err := json.NewDecoder(r.Body).Decode(&struct {
+			Result interface{} `json:"result"`
+		}{v}); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+type checkResult struct {
+	Valid    bool   `json:"valid"`
+	Filtered string `json:"filtered"`
+}
+
+func decodeCheckResponse(_ context.Context, r *http.Response) (interface{}, error) {
+	if r.StatusCode != http.StatusOK {
+		return nil, decodeError(r)
+	}
+	var result checkResult
+	if err := json.NewDecoder(r.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func decodeError(r *http.Response) error {
+	var body struct {
+		Error   string `json:"error"`
+		Reasons []Hit  `json:"reasons"`
+	}
+	data, _ := ioutil.ReadAll(r.Body)
+	if err := json.Unmarshal(data, &body); err != nil || len(body.Error) == 0 {
+		body.Error = strings.TrimSpace(string(data))
+	}
+	if r.StatusCode == http.StatusUnprocessableEntity {
+		return &BlockedError{body.Reasons}
+	}
+	return &StatusError{r.StatusCode, body.Error}
+}
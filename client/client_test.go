@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyServer answers status to the first failures requests, then body
+type flakyServer struct {
+	mu       sync.Mutex
+	requests int
+	failures int
+	status   int
+	body     string
+}
+
+func (s *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	w.Header().Set("Content-Type", "application/json")
+	if s.requests <= s.failures {
+		w.WriteHeader(s.status)
+		w.Write([]byte(`{"error":"server is overloaded"}`))
+		return
+	}
+	w.Write([]byte(s.body))
+}
+
+func (s *flakyServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func TestDecode(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if r.FormValue("message") != "so bad" {
+			http.Error(w, `{"error":"message is required"}`, http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/filter":
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"error":"message contains blocked words","reasons":[{"rule":"r1","word":"bad","start":3,"end":6,"rune_start":3,"rune_end":6}]}`))
+		case "/api/check":
+			w.Write([]byte(`{"valid":false,"filtered":"so ***"}`))
+		case "/api/detect":
+			w.Write([]byte(`{"result":[{"rule":"r1","word":"bad","start":3,"end":6,"rune_start":3,"rune_end":6}]}`))
+		}
+	}))
+	defer server.Close()
+	c, err := New(server.URL + "/api/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	bad := Hit{Rule: "r1", Word: "bad", Start: 3, End: 6, RuneStart: 3, RuneEnd: 6}
+
+	valid, filtered, err := c.Check(ctx, "so bad")
+	if err != nil || valid || filtered != "so ***" || path != "/api/check" {
+		t.Errorf("Check = %v, %q, %v on %s", valid, filtered, err, path)
+	}
+	hits, err := c.Detect(ctx, "so bad")
+	if err != nil || !reflect.DeepEqual(hits, []Hit{bad}) {
+		t.Errorf("Detect = %+v, %v", hits, err)
+	}
+	_, err = c.Filter(ctx, "so bad")
+	if blocked, ok := err.(*BlockedError); !ok || !reflect.DeepEqual(blocked.Reasons, []Hit{bad}) {
+		t.Errorf("Filter of a blocked message = %v, want *BlockedError", err)
+	}
+	_, err = c.Validate(ctx, "")
+	if status, ok := err.(*StatusError); !ok || status.Code != http.StatusBadRequest || status.Message != "message is required" {
+		t.Errorf("Validate without message = %v, want 400 message is required", err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		status   int
+		requests int
+		failed   bool
+	}{
+		{"ok", 0, 0, 1, false},
+		{"retried", 2, http.StatusServiceUnavailable, 3, false},
+		{"too many failures", 3, http.StatusServiceUnavailable, 3, true},
+		{"not retried", 1, http.StatusBadRequest, 1, true},
+		{"blocked", 1, http.StatusUnprocessableEntity, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &flakyServer{failures: tt.failures, status: tt.status, body: `{"result":true}`}
+			server := httptest.NewServer(s)
+			defer server.Close()
+			c, err := New(server.URL, Retry(2, time.Millisecond), CircuitBreaker(0, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			valid, err := c.Validate(context.Background(), "fine")
+			if (err != nil) != tt.failed || (err == nil && !valid) {
+				t.Errorf("Validate = %v, %v", valid, err)
+			}
+			if s.count() != tt.requests {
+				t.Errorf("%d requests, want %d", s.count(), tt.requests)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	s := &flakyServer{failures: 2, status: http.StatusInternalServerError, body: `{"result":[{"result":"so ***"}]}`}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	c, err := New(server.URL, Retry(0, 0), CircuitBreaker(2, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if _, err := c.FilterBatch(ctx, []string{"so bad"}); err == nil {
+			t.Fatal("failed request succeeded")
+		}
+	}
+	// the circuit is shared by the methods of the client
+	if _, err := c.Validate(ctx, "fine"); err != ErrCircuitOpen {
+		t.Errorf("request with the circuit open = %v, want ErrCircuitOpen", err)
+	}
+	if s.count() != 2 {
+		t.Errorf("%d requests with the circuit open, want 2", s.count())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	results, err := c.FilterBatch(ctx, []string{"so bad"})
+	if err != nil || !reflect.DeepEqual(results, []FilterResult{{Text: "so ***"}}) {
+		t.Errorf("FilterBatch after the cooldown = %+v, %v", results, err)
+	}
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// ErrCircuitOpen is returned without calling the server while the circuit
+// breaker is open
+var ErrCircuitOpen = errors.New("wego: circuit breaker is open")
+
+// temporary tells if a request failed with err may succeed when retried,
+// errors of the server are only temporary for 5xx responses
+func temporary(err error) bool {
+	switch err := err.(type) {
+	case *StatusError:
+		return err.Temporary()
+	case *BlockedError:
+		return false
+	}
+	return err != context.Canceled && err != context.DeadlineExceeded
+}
+
+// retry retries temporary failures with an exponential backoff and jitter
+func retry(retries int, backoff time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			wait := backoff
+			for i := 0; ; i++ {
+				response, err := next(ctx, request)
+				if err == nil || i >= retries || !temporary(err) {
+					return response, err
+				}
+
+				jitter := time.Duration(rand.Int63n(int64(wait)/2 + 1))
+				select {
+				case <-time.After(wait/2 + jitter):
+				case <-ctx.Done():
+					return nil, err
+				}
+				wait *= 2
+			}
+		}
+	}
+}
+
+// circuitBreaker opens after failures consecutive temporary failures, a
+// single request is let through after cooldown and closes it on success
+func circuitBreaker(failures int, cooldown time.Duration) endpoint.Middleware {
+	if failures <= 0 {
+		return func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+	}
+
+	var mu sync.Mutex
+	var consecutive int
+	var openUntil time.Time
+	var probing bool
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			mu.Lock()
+			if consecutive >= failures {
+				if probing || time.Now().Before(openUntil) {
+					mu.Unlock()
+					return nil, ErrCircuitOpen
+				}
+				probing = true
+			}
+			mu.Unlock()
+
+			response, err := next(ctx, request)
+
+			mu.Lock()
+			defer mu.Unlock()
+			probing = false
+			if err != nil && temporary(err) {
+				consecutive++
+				if consecutive >= failures {
+					openUntil = time.Now().Add(cooldown)
+				}
+			} else {
+				consecutive = 0
+			}
+			return response, err
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		s       string
+		n       int
+		invalid bool
+	}{
+		{"", 0, false},
+		{"10.0.0.0/8", 1, false},
+		{" 10.0.0.1 , ::1,fd00::/8, ", 3, false},
+		{"10.0.0.0/33", 0, true},
+		{"proxy.local", 0, true},
+		{"10.0.0.1,10.0.0", 0, true},
+	}
+	for _, tt := range tests {
+		trusted, err := parseTrustedProxies(tt.s)
+		if (err != nil) != tt.invalid || len(trusted) != tt.n {
+			t.Errorf("parseTrustedProxies(%q) = %v, %v, want %d networks", tt.s, trusted, err, tt.n)
+		}
+	}
+
+	// single addresses are networks of their own
+	trusted, _ := parseTrustedProxies("10.0.0.1,::1")
+	for ip, want := range map[string]bool{"10.0.0.1": true, "10.0.0.2": false, "::1": true, "::2": false} {
+		if got := isTrusted(ip, trusted); got != want {
+			t.Errorf("isTrusted(%s) = %v, want %v", ip, got, want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		header map[string][]string
+		want   string
+	}{
+		{"direct", "203.0.113.9:1234", nil, "203.0.113.9"},
+		{"untrusted forwarded", "203.0.113.9:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.9"},
+		{"untrusted real ip", "203.0.113.9:1234", map[string][]string{"X-Real-IP": {"198.51.100.1"}}, "203.0.113.9"},
+		{"forwarded", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1"},
+		{"spoofed hops", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1, 10.0.0.2"}}, "198.51.100.1"},
+		{"several headers", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"1.2.3.4", "198.51.100.1"}}, "198.51.100.1"},
+		{"only proxies", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"invalid hop", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1, unknown"}}, "10.0.0.1"},
+		{"real ip", "10.0.0.1:1234", map[string][]string{"X-Real-IP": {" 198.51.100.1 "}}, "198.51.100.1"},
+		{"invalid real ip", "10.0.0.1:1234", map[string][]string{"X-Real-IP": {"unknown"}}, "10.0.0.1"},
+		{"no port", "10.0.0.1", map[string][]string{"X-Real-IP": {"198.51.100.1"}}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for key, values := range tt.header {
+				for _, value := range values {
+					r.Header.Add(key, value)
+				}
+			}
+
+			var got string
+			handler := clientIPHandler(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = clientIPFrom(r.Context())
+			}), trusted)
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+			if got != tt.want {
+				t.Errorf("client ip = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
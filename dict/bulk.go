@@ -0,0 +1,234 @@
+package dict
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxLength is the max number of characters of words added by Add
+var MaxLength = 50
+
+// Candidate is an entry to be added with the line it comes from, Err is set
+// when the line can't be read as an entry
+type Candidate struct {
+	Line  int
+	Entry Entry
+	Err   error
+}
+
+// ParseCandidates reads candidates from r in the format of file, see Load.
+// Lines of csv and text files are numbered from 1 like in editors, blank
+// and comment lines are skipped; entries of json arrays are numbered by
+// their position.
+func ParseCandidates(file string, r io.Reader) ([]Candidate, error) {
+	r = skipBOM(r, &Report{})
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return parseCSVCandidates(r)
+	case ".json":
+		return parseJSONCandidates(r)
+	default:
+		return parseTextCandidates(r)
+	}
+}
+
+func parseTextCandidates(r io.Reader) ([]Candidate, error) {
+	var candidates []Candidate
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		trimmed := strings.TrimSpace(scanner.Text())
+		if len(trimmed) == 0 || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		candidates = append(candidates, Candidate{Line: line, Entry: Entry{Word: strings.Fields(trimmed)[0]}})
+	}
+	return candidates, scanner.Err()
+}
+
+func parseCSVCandidates(r io.Reader) ([]Candidate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var candidates []Candidate
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return candidates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) == 0 || (first && strings.EqualFold(record[0], "word")) {
+			continue
+		}
+
+		c := Candidate{Line: line, Entry: Entry{Word: record[0]}}
+		if len(record) > 1 {
+			c.Entry.Category = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 {
+			if severity := strings.TrimSpace(record[2]); len(severity) > 0 {
+				if c.Entry.Severity, err = strconv.Atoi(severity); err != nil {
+					c.Err = fmt.Errorf("invalid severity %q", severity)
+				}
+			}
+		}
+		if len(record) > 3 {
+			c.Entry.ID = strings.TrimSpace(record[3])
+		}
+		if len(record) > 4 && c.Err == nil {
+			c.Entry.Expires, c.Err = parseExpiry(record[4])
+		}
+		candidates = append(candidates, c)
+	}
+}
+
+func parseJSONCandidates(r io.Reader) ([]Candidate, error) {
+	var raws []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raws); err != nil {
+		return nil, err
+	}
+
+	candidates := make([]Candidate, len(raws))
+	for i, raw := range raws {
+		c := &candidates[i]
+		c.Line = i + 1
+		var err error
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte(`"`)) {
+			err = json.Unmarshal(raw, &c.Entry.Word)
+		} else {
+			err = json.Unmarshal(raw, &c.Entry)
+		}
+		if err != nil {
+			c.Err = errors.New("not a word or an entry")
+		}
+	}
+	return candidates, nil
+}
+
+// Import statuses of candidates
+const (
+	ImportOK        = "ok"
+	ImportInvalid   = "invalid"
+	ImportDuplicate = "duplicate" // same word as a previous candidate
+	ImportExists    = "exists"    // already in the stable dictionary
+	ImportConflict  = "conflict"  // disabled by /admin/words, it wouldn't match
+)
+
+// ImportLine is the outcome of a candidate
+type ImportLine struct {
+	Line   int    `json:"line"`
+	Word   string `json:"word"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+var (
+	// added are the entries added by Add, kept when dictionaries are reloaded
+	added   []Entry
+	addedMu sync.Mutex
+)
+
+// Add checks candidates and adds them to the dictionaries in use unless
+// dryRun is set or one of them is invalid or conflicting, duplicate and
+// existing words are skipped. The report has a line per candidate and
+// committed tells if the entries were added, err is set if they would make
+// a dictionary larger than MaxSize.
+func Add(candidates []Candidate, dryRun bool) (report []ImportLine, committed bool, err error) {
+	installMu.Lock()
+	defer installMu.Unlock()
+	addedMu.Lock()
+	defer addedMu.Unlock()
+
+	d := dictionary()
+	existing := make(map[string]bool, d.Len())
+	for i := range d.Entries() {
+		existing[string(joinUnits(splitUnits(d.Entries()[i].Word)))] = true
+	}
+	disabledWords := disabled.Load().(map[string]bool)
+	now := time.Now()
+
+	var entries []Entry
+	ok := true
+	seen := make(map[string]bool, len(candidates))
+	for _, c := range candidates {
+		word := strings.TrimSpace(c.Entry.Word)
+		key := string(joinUnits(splitUnits(word)))
+		line := ImportLine{Line: c.Line, Word: word, Status: ImportOK}
+		switch {
+		case c.Err != nil:
+			line.Status, line.Reason = ImportInvalid, c.Err.Error()
+		case !utf8.ValidString(c.Entry.Word):
+			line.Status, line.Reason = ImportInvalid, "invalid utf-8"
+		case len(key) == 0:
+			line.Status, line.Reason = ImportInvalid, "empty word"
+		case utf8.RuneCountInString(word) > MaxLength:
+			line.Status, line.Reason = ImportInvalid, fmt.Sprintf("longer than %d characters", MaxLength)
+		case c.Entry.expiredAt(now):
+			line.Status, line.Reason = ImportInvalid, "already expired"
+		case seen[key]:
+			line.Status = ImportDuplicate
+		case existing[key]:
+			line.Status = ImportExists
+		case disabledWords[key]:
+			line.Status, line.Reason = ImportConflict, "word is disabled"
+		}
+		seen[key] = true
+		report = append(report, line)
+
+		switch line.Status {
+		case ImportOK:
+			e := c.Entry
+			e.Word = word
+			entries = append(entries, e)
+		case ImportInvalid, ImportConflict:
+			ok = false
+		}
+	}
+	if dryRun || !ok || len(entries) == 0 {
+		return report, false, nil
+	}
+
+	stable := NewDictionary(append(d.Entries()[:d.Len():d.Len()], entries...))
+	if err := checkSize(stable); err != nil {
+		return report, false, fmt.Errorf("无法添加到%s字典: %v", Stable, err)
+	}
+	c := canary.Load().(*Dictionary)
+	if c != nil {
+		c = NewDictionary(append(c.Entries()[:c.Len():c.Len()], entries...))
+		if err := checkSize(c); err != nil {
+			return report, false, fmt.Errorf("无法添加到%s字典: %v", Canary, err)
+		}
+	}
+
+	added = append(added, entries...)
+	current.Store(stable)
+	record(Stable, stable)
+	if c != nil {
+		canary.Store(c)
+		record(Canary, c)
+	}
+	purgeCache()
+	return report, true, nil
+}
+
+// addedEntries returns the entries added by Add
+func addedEntries() []Entry {
+	addedMu.Lock()
+	defer addedMu.Unlock()
+	return added[:len(added):len(added)]
+}
@@ -0,0 +1,50 @@
+package dict
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	use(t, "bad")
+	t.Cleanup(func() {
+		addedMu.Lock()
+		added = nil
+		addedMu.Unlock()
+	})
+
+	// reloading while adding loses no word
+	reloaded := make([]Entry, 5000)
+	for i := range reloaded {
+		reloaded[i] = Entry{Word: fmt.Sprintf("loaded%d", i)}
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if _, err := Replace(reloaded); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		word := fmt.Sprintf("word%d", i)
+		if _, committed, err := Add([]Candidate{{Line: 1, Entry: Entry{Word: word}}}, false); err != nil || !committed {
+			t.Fatalf("Add(%q) = %v, %v", word, committed, err)
+		}
+		if !ExistInvalidWord(word) {
+			t.Errorf("%q added but lost", word)
+		}
+	}
+	wg.Wait()
+
+	// nothing is added beyond MaxSize
+	defer func(size int64) { MaxSize = size }(MaxSize)
+	MaxSize = dictionary().Size()
+	_, committed, err := Add([]Candidate{{Line: 1, Entry: Entry{Word: "toolarge"}}}, false)
+	if err == nil || committed || ExistInvalidWord("toolarge") {
+		t.Errorf("Add beyond MaxSize = %v, %v", committed, err)
+	}
+}
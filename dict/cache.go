@@ -0,0 +1,113 @@
+package dict
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// MaxCachedText is the length in bytes of the longest text whose matches are
+// cached, see SetCacheSize
+var MaxCachedText = 4 << 10
+
+// matchCache keeps the matches of the texts matched last, it's purged
+// whenever something changes which words can match: a dictionary is loaded,
+// words or sources are disabled or enabled, entries expire
+type matchCache struct {
+	sync.Mutex
+	size       int
+	generation int // of the purges, matches found before one are stale
+	entries    map[cacheKey]*list.Element
+	order      *list.List // most recently used first
+}
+
+type cacheKey struct {
+	d    *Dictionary
+	mask rune
+	text string
+}
+
+type cached struct {
+	key     cacheKey
+	matches []Match
+}
+
+var cache = matchCache{entries: make(map[cacheKey]*list.Element), order: list.New()}
+
+// SetCacheSize sets the number of texts whose matches are cached, 0 disables
+// the cache, which is the default. The least recently matched texts are
+// dropped once it's full.
+func SetCacheSize(n int) error {
+	if n < 0 {
+		return fmt.Errorf("invalid cache size %d", n)
+	}
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.size = n
+	for cache.order.Len() > n {
+		cache.remove(cache.order.Back())
+	}
+	return nil
+}
+
+// CacheSize returns the number of texts whose matches are cached
+func CacheSize() int {
+	cache.Lock()
+	defer cache.Unlock()
+	return cache.size
+}
+
+// purgeCache empties the cache once the matches it holds may have changed
+func purgeCache() {
+	cache.Lock()
+	defer cache.Unlock()
+
+	cache.generation++
+	cache.entries = make(map[cacheKey]*list.Element)
+	cache.order.Init()
+}
+
+// findCached is find looking up the matches of text in the cache first,
+// texts longer than MaxCachedText are always matched
+func findCached(ctx context.Context, text string) ([]Match, error) {
+	cache.Lock()
+	if cache.size == 0 || len(text) > MaxCachedText {
+		cache.Unlock()
+		return find(ctx, text)
+	}
+	// the dictionary is read after the generation, so that it's the one
+	// loaded last if the matches aren't stale
+	generation := cache.generation
+	d := dictionaryFor(ctx, text)
+	key := cacheKey{d, replacementIn(ctx).Mask, text}
+	if e, ok := cache.entries[key]; ok {
+		cache.order.MoveToFront(e)
+		matches := append([]Match(nil), e.Value.(*cached).matches...)
+		cache.Unlock()
+		return matches, nil
+	}
+	cache.Unlock()
+
+	matches, err := findIn(ctx, d, text)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	if _, ok := cache.entries[key]; !ok && cache.size > 0 && cache.generation == generation {
+		cache.entries[key] = cache.order.PushFront(&cached{key, append([]Match(nil), matches...)})
+		for cache.order.Len() > cache.size {
+			cache.remove(cache.order.Back())
+		}
+	}
+	return matches, nil
+}
+
+// remove drops e from c, which must be locked
+func (c *matchCache) remove(e *list.Element) {
+	delete(c.entries, e.Value.(*cached).key)
+	c.order.Remove(e)
+}
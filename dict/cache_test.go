@@ -0,0 +1,53 @@
+package dict
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	use(t, "坏词", "bad")
+	if err := SetCacheSize(2); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { SetCacheSize(0) })
+
+	ctx := context.Background()
+	detect := func(text string) int {
+		hits, err := DetectContext(ctx, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(hits)
+	}
+	tests := []struct {
+		name   string
+		change func()
+		text   string
+		hits   int
+		cached int
+	}{
+		{"first match", nil, "一个坏词", 1, 1},
+		{"cached", nil, "一个坏词", 1, 1},
+		{"another text", nil, "bad bad", 2, 2},
+		{"least recently matched dropped", nil, "clean", 0, 2},
+		{"disabling purges", func() { Disable("bad") }, "bad bad", 0, 1},
+		{"enabling purges", func() { Enable("bad") }, "bad bad", 2, 1},
+		{"loading purges", func() { use(t, "bad") }, "一个坏词", 0, 1},
+		{"shrinking drops", func() { SetCacheSize(0) }, "bad", 1, 0},
+	}
+	for _, tt := range tests {
+		if tt.change != nil {
+			tt.change()
+		}
+		if hits := detect(tt.text); hits != tt.hits {
+			t.Errorf("%s: %d hits in %q, want %d", tt.name, hits, tt.text, tt.hits)
+		}
+		if n := cache.order.Len(); n != tt.cached {
+			t.Errorf("%s: %d texts cached, want %d", tt.name, n, tt.cached)
+		}
+	}
+	if err := SetCacheSize(-1); err == nil {
+		t.Error("SetCacheSize accepted a negative size")
+	}
+}
@@ -0,0 +1,146 @@
+package dict
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Hit is a word found in text, Start and End are byte offsets while
+// RuneStart and RuneEnd count unicode code points for non Go consumers
+type Hit struct {
+	Rule      string `json:"rule"`
+	Word      string `json:"word"`
+	Category  string `json:"category,omitempty"`
+	Severity  int    `json:"severity,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	RuneStart int    `json:"rune_start"`
+	RuneEnd   int    `json:"rune_end"`
+	Context   string `json:"context,omitempty"`
+}
+
+// Detect Return words defined in dictionary found in text with their offsets
+func Detect(text string) []Hit {
+	hits, _ := DetectContext(context.Background(), text)
+	return hits
+}
+
+// DetectContext is Detect giving up once ctx is done
+func DetectContext(ctx context.Context, text string) ([]Hit, error) {
+	matches, err := findCached(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return hitsOf(text, matches), nil
+}
+
+// Detect returns the entries of d found in text, like the package level
+// Detect does with the dictionary in use
+func (d *Dictionary) Detect(text string) []Hit {
+	matches, _ := findIn(context.Background(), d, text)
+	return hitsOf(text, matches)
+}
+
+func hitsOf(text string, matches []Match) []Hit {
+	hits := make([]Hit, 0, len(matches))
+
+	// matches are ordered, so runes are counted once, from the start of the
+	// previous match when they overlap
+	offset, runes := 0, 0
+	for _, m := range matches {
+		if m.Start < offset {
+			prev := hits[len(hits)-1]
+			offset, runes = prev.Start, prev.RuneStart
+		}
+		runes += utf8.RuneCountInString(text[offset:m.Start])
+		start := runes
+		runes += utf8.RuneCountInString(text[m.Start:m.End])
+		offset = m.End
+
+		hits = append(hits, Hit{
+			Rule:      m.Entry.ID,
+			Word:      m.Entry.Word,
+			Category:  m.Entry.Category,
+			Severity:  m.Entry.Severity,
+			Source:    m.Entry.Source,
+			Start:     m.Start,
+			End:       m.End,
+			RuneStart: start,
+			RuneEnd:   runes,
+		})
+	}
+	return hits
+}
+
+// Delimiters end sentences in Sentences
+var Delimiters = "。！？!?；;\n"
+
+// ContextRunes is the number of runes around a hit included in its context
+var ContextRunes = 10
+
+// Sentence is a part of text ended by a delimiter with its own verdict, the
+// offsets of hits are relative to the whole text
+type Sentence struct {
+	Text      string `json:"text"`
+	Start     int    `json:"start"`
+	End       int    `json:"end"`
+	RuneStart int    `json:"rune_start"`
+	RuneEnd   int    `json:"rune_end"`
+	Valid     bool   `json:"valid"`
+	Hits      []Hit  `json:"hits"`
+}
+
+// Sentences splits text into sentences and detects words in each of them,
+// hits come with ContextRunes runes of surrounding text
+func Sentences(text string) []Sentence {
+	sentences, _ := SentencesContext(context.Background(), text)
+	return sentences
+}
+
+// SentencesContext is Sentences giving up once ctx is done
+func SentencesContext(ctx context.Context, text string) ([]Sentence, error) {
+	hits, err := DetectContext(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(text)
+	for i := range hits {
+		start, end := hits[i].RuneStart-ContextRunes, hits[i].RuneEnd+ContextRunes
+		if start < 0 {
+			start = 0
+		}
+		if end > len(runes) {
+			end = len(runes)
+		}
+		hits[i].Context = string(runes[start:end])
+	}
+
+	var sentences []Sentence
+	start, runeStart, runeCount := 0, 0, 0
+	for i, r := range text {
+		runeCount++
+		if !strings.ContainsRune(Delimiters, r) {
+			continue
+		}
+		end := i + utf8.RuneLen(r)
+		sentences = append(sentences, Sentence{Text: text[start:end], Start: start, End: end, RuneStart: runeStart, RuneEnd: runeCount})
+		start, runeStart = end, runeCount
+	}
+	if start < len(text) || len(sentences) == 0 {
+		sentences = append(sentences, Sentence{Text: text[start:], Start: start, End: len(text), RuneStart: runeStart, RuneEnd: runeCount})
+	}
+
+	for i := range sentences {
+		s := &sentences[i]
+		s.Hits = []Hit{}
+		for _, hit := range hits {
+			if hit.Start >= s.Start && hit.Start < s.End {
+				s.Hits = append(s.Hits, hit)
+			}
+		}
+		s.Valid = len(s.Hits) == 0
+	}
+	return sentences, nil
+}
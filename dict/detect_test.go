@@ -0,0 +1,53 @@
+package dict
+
+import (
+	"context"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDetectOffsets(t *testing.T) {
+	use(t, "坏词", "bad", "caf\u00e9")
+	type offsets struct {
+		word                           string
+		start, end, runeStart, runeEnd int
+	}
+	tests := []struct {
+		name string
+		text string
+		want []offsets
+	}{
+		{"ascii", "so bad", []offsets{{"bad", 3, 6, 3, 6}}},
+		{"cjk", "一个坏词", []offsets{{"坏词", 6, 12, 2, 4}}},
+		{"emoji", "👍bad", []offsets{{"bad", 4, 7, 1, 4}}},
+		{"zwj sequence", "👨‍👩‍👧 坏词", []offsets{{"坏词", 19, 25, 6, 8}}},
+		{"flag", "🇨🇳坏词🇨🇳", []offsets{{"坏词", 8, 14, 2, 4}}},
+		{"combining before", "e\u0301 bad", []offsets{{"bad", 4, 7, 3, 6}}},
+		{"precomposed word", "un caf\u00e9", []offsets{{"caf\u00e9", 3, 8, 3, 7}}},
+		{"several", "😀坏词 and bad😀bad", []offsets{
+			{"坏词", 4, 10, 1, 3},
+			{"bad", 15, 18, 8, 11},
+			{"bad", 22, 25, 12, 15},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := DetectContext(context.Background(), tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hits) != len(tt.want) {
+				t.Fatalf("Detect(%q) = %+v, want %+v", tt.text, hits, tt.want)
+			}
+			for i, h := range hits {
+				got := offsets{h.Word, h.Start, h.End, h.RuneStart, h.RuneEnd}
+				if got != tt.want[i] {
+					t.Errorf("Detect(%q)[%d] = %+v, want %+v", tt.text, i, got, tt.want[i])
+				}
+				if n := utf8.RuneCountInString(tt.text[:h.Start]); n != h.RuneStart {
+					t.Errorf("Detect(%q)[%d] rune start %d, %d runes before it", tt.text, i, h.RuneStart, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package dict
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// detector finds spam payloads no dictionary can list, like phone numbers,
+// its matches are reported as the entry of the detector
+type detector struct {
+	entry Entry
+	find  func(text string) [][]int
+}
+
+// builtinDetectors are the detectors available to SetDetectors, each has a
+// word in angle brackets by which it's disabled like dictionary words
+var builtinDetectors = map[string]*detector{
+	"phone":     newDetector("phone", digitsBounded(regexp.MustCompile(`(?:\+?86[- ]?)?1[3-9]\d(?:[- ]?\d{4}){2}|0\d{2,3}-\d{7,8}`))),
+	"qq":        newDetector("qq", all(regexp.MustCompile(`(?i)(?:qq|扣扣|企鹅)\s*(?:号码|号)?\s*[:：]?\s*[1-9]\d{4,10}`))),
+	"bank-card": newDetector("bank-card", luhnChecked(digitsBounded(regexp.MustCompile(`\d{4}(?:[- ]?\d{4}){3}(?:\d{1,3})?`)))),
+	"url":       newDetector("url", all(regexp.MustCompile(`(?i)(?:https?://|www\.)[a-z0-9\-._~:/?#\[\]@!$&'()*+,;=%]+|\b[a-z0-9][a-z0-9\-]*(?:\.[a-z0-9\-]+)*\.(?:com|cn|net|org|top|xyz|cc|io|me|info|vip|club)\b(?:/[a-z0-9\-._~:/?#\[\]@!$&'()*+,;=%]*)?`))),
+}
+
+// detectors used when matching, see SetDetectors
+var detectors []*detector
+
+func newDetector(name string, find func(text string) [][]int) *detector {
+	return &detector{Entry{ID: "detector-" + name, Word: "<" + name + ">", Category: name}, find}
+}
+
+// DetectorNames returns the names of builtin detectors
+func DetectorNames() []string {
+	names := make([]string, 0, len(builtinDetectors))
+	for name := range builtinDetectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetDetectors sets the builtin detectors run along dictionaries, none by
+// default, it must be called before serving
+func SetDetectors(names ...string) error {
+	ds, err := builtins(names)
+	if err != nil {
+		return err
+	}
+	detectors = ds
+	return nil
+}
+
+// builtins returns the builtin detectors named names
+func builtins(names []string) ([]*detector, error) {
+	ds := make([]*detector, 0, len(names))
+	for _, name := range names {
+		d, ok := builtinDetectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// detect merges the matches of the detectors of d in text into matches,
+// overlapping ones are resolved by the overlap policy of d like in the
+// dictionary. Dictionaries compiled by New run the detectors of WithDetectors
+// instead of the ones of SetDetectors.
+func (d *Dictionary) detect(text string, matches []Match) []Match {
+	ds := detectors
+	if d.standalone {
+		ds = d.detectors
+	}
+	found := false
+	for _, det := range ds {
+		if !d.active(&det.entry) {
+			continue
+		}
+		for _, loc := range det.find(text) {
+			matches = append(matches, Match{Entry: &det.entry, Start: loc[0], End: loc[1]})
+			found = true
+		}
+	}
+	if !found {
+		return matches
+	}
+	return resolve(matches, d.overlap)
+}
+
+func all(re *regexp.Regexp) func(text string) [][]int {
+	return func(text string) [][]int { return re.FindAllStringIndex(text, -1) }
+}
+
+// digitsBounded drops the matches of re which are part of a longer number
+func digitsBounded(re *regexp.Regexp) func(text string) [][]int {
+	return func(text string) [][]int {
+		locs := re.FindAllStringIndex(text, -1)
+		result := locs[:0]
+		for _, loc := range locs {
+			if loc[0] > 0 && isDigit(text[loc[0]-1]) || loc[1] < len(text) && isDigit(text[loc[1]]) {
+				continue
+			}
+			result = append(result, loc)
+		}
+		return result
+	}
+}
+
+// luhnChecked keeps the matches of find with a valid Luhn check digit, which
+// bank card numbers have
+func luhnChecked(find func(text string) [][]int) func(text string) [][]int {
+	return func(text string) [][]int {
+		locs := find(text)
+		result := locs[:0]
+		for _, loc := range locs {
+			if luhn(text[loc[0]:loc[1]]) {
+				result = append(result, loc)
+			}
+		}
+		return result
+	}
+}
+
+func luhn(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		if !isDigit(number[i]) {
+			continue
+		}
+		n := int(number[i] - '0')
+		if double {
+			if n *= 2; n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -1,49 +1,350 @@
 package dict
 
 import (
+	"context"
 	"fmt"
-	"regexp"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unicode/utf8"
+)
 
-	"github.com/goofansu/sego"
+// Versions of dictionary a text can be matched against
+const (
+	Stable = "stable"
+	Canary = "canary"
 )
 
-var segmenter sego.Segmenter
+// CanaryPercent is the percentage of texts matched against the canary
+// dictionary once it's loaded, texts are routed by their hash so the same
+// text always gets the same version. It's read without synchronization, set
+// it before serving texts.
+var CanaryPercent int
+
+var current, canary atomic.Value
+
+func init() {
+	current.Store(NewDictionary(nil))
+	canary.Store((*Dictionary)(nil))
+}
+
+// dictionary returns the stable dictionary
+func dictionary() *Dictionary {
+	return current.Load().(*Dictionary)
+}
+
+// dictionaryFor returns the dictionary text is routed to, among the ones
+// pinned in ctx if any
+func dictionaryFor(ctx context.Context, text string) *Dictionary {
+	p := pinnedIn(ctx)
+	if versionOf(text, p.canary) == Canary {
+		return p.canary
+	}
+	return p.stable
+}
 
-// Load dictionaries from dictPath
-func Load(dictPath string) {
-	segmenter.LoadDictionary(dictPath)
+// Version returns the version of dictionary text is matched against
+func Version(text string) string {
+	return versionOf(text, canary.Load().(*Dictionary))
+}
+
+func versionOf(text string, canary *Dictionary) string {
+	if CanaryPercent <= 0 || canary == nil {
+		return Stable
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(text))
+	if int(h.Sum32()%100) < CanaryPercent {
+		return Canary
+	}
+	return Stable
+}
+
+var loaded int32
+
+// Ready tells if the stable dictionary has been loaded, texts are matched
+// against an empty dictionary until then
+func Ready() bool {
+	return atomic.LoadInt32(&loaded) == 1
+}
+
+// Load dictionaries from dictPath, a glob pattern of files or an http(s) url.
+// Supported formats are plain text (one word per line), csv
+// (word,category,severity) and json, detected by extension
+func Load(dictPath string) error {
+	if err := load(dictPath, &current); err != nil {
+		return err
+	}
+	atomic.StoreInt32(&loaded, 1)
+	return nil
+}
+
+// LoadCanary loads the canary dictionary from dictPath, see CanaryPercent
+func LoadCanary(dictPath string) error {
+	return load(dictPath, &canary)
+}
+
+// Watch reloads dictionaries from dictPath every interval until stop is
+// closed, remote dictionaries are only reloaded when they have changed. The
+// dictionary in use is kept when reloading fails.
+func Watch(dictPath string, interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	watch(dictPath, &current, interval, stop, onError)
+}
+
+// WatchCanary is Watch for the canary dictionary
+func WatchCanary(dictPath string, interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	watch(dictPath, &canary, interval, stop, onError)
+}
+
+func watch(dictPath string, dst *atomic.Value, interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := load(dictPath, dst); err != nil {
+				onError(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// load replaces the dictionary in dst, nothing is done when a remote
+// dictionary has not been modified
+func load(dictPath string, dst *atomic.Value) error {
+	var report Report
+	entries, err := read(dictPath, &report, true)
+	if err == ErrNotModified {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return install(entries, dst, &report, dictPath)
+}
+
+// Read returns the entries of the dictionaries at dictPath without loading
+// them, see Load
+func Read(dictPath string) ([]Entry, error) {
+	var report Report
+	entries, err := read(dictPath, &report, true)
+	if err != nil {
+		return nil, err
+	}
+	return cleanEntries(entries, &report), nil
+}
+
+// read returns the entries at dictPath, a remote dictionary is fetched
+// again even if it has not changed unless conditional is set
+func read(dictPath string, report *Report, conditional bool) ([]Entry, error) {
+	if isRemote(dictPath) {
+		entries, err := fetch(dictPath, report, conditional)
+		if err != nil && err != ErrNotModified {
+			return nil, fmt.Errorf("无法载入字典 %q: %v", dictPath, err)
+		}
+		setSource(entries, sourceOf(dictPath))
+		return entries, err
+	}
+
+	files, err := filepath.Glob(dictPath)
+	if err != nil {
+		return nil, fmt.Errorf("glob pattern error: %s", dictPath)
+	}
+	var entries []Entry
+	for _, file := range files {
+		log.Printf("载入词典 %s", file)
+		es, err := loadFile(file, report)
+		if err != nil {
+			return nil, fmt.Errorf("无法载入字典文件 %q: %v", file, err)
+		}
+		setSource(es, sourceOf(file))
+		entries = append(entries, es...)
+		report.Files++
+	}
+	return entries, nil
+}
+
+// Replace replaces the stable dictionary with one of entries, like pushed by
+// a sync daemon, it's kept until the dictionary is loaded again
+func Replace(entries []Entry) (*Dictionary, error) {
+	var report Report
+	if err := install(entries, &current, &report, "push"); err != nil {
+		return nil, err
+	}
+	atomic.StoreInt32(&loaded, 1)
+	return dictionary(), nil
+}
+
+// installMu serializes the changes of the dictionaries in use by loading,
+// Replace and Add, so that none of them is lost
+var installMu sync.Mutex
+
+// install compiles entries with the ones added by Add into the dictionary
+// of dst, source names where they come from in errors
+func install(entries []Entry, dst *atomic.Value, report *Report, source string) error {
+	installMu.Lock()
+	defer installMu.Unlock()
+
+	entries = cleanEntries(append(entries, addedEntries()...), report)
+
+	if len(entries) >= 1000000 {
+		log.Printf("编译词典，共%d个词", len(entries))
+	}
+	d := NewDictionary(entries)
+	if err := checkSize(d); err != nil {
+		return fmt.Errorf("无法载入字典 %q: %v", source, err)
+	}
+	dst.Store(d)
+	purgeCache()
+	if dst == &canary {
+		record(Canary, d)
+	} else {
+		record(Stable, d)
+	}
+	log.Printf("词典载入完毕，%s，占用%d字节", report, d.Size())
+	return nil
+}
+
+func loadFile(file string, report *Report) ([]Entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+	r, err := decode(file, newProgressReader(f, file, size))
+	if err != nil {
+		return nil, err
+	}
+	return parserFor(file)(skipBOM(r, report), report)
+}
+
+// Words Return all words in the stable dictionary
+func Words() []string {
+	entries := dictionary().Entries()
+	words := make([]string, len(entries))
+	for i := range entries {
+		words[i] = entries[i].Word
+	}
+	return words
+}
+
+// Entries returns the entries of the stable dictionary
+func Entries() []Entry {
+	return dictionary().Entries()
 }
 
 // ExistInvalidWord Check if text contains words defined in dictionary
 func ExistInvalidWord(text string) bool {
-	segments := getSegments(text)
-	for _, seg := range segments {
-		token := seg.Token()
-		if token.Frequency() > 1 {
-			return true
-		}
+	exist, _ := ExistInvalidWordContext(context.Background(), text)
+	return exist
+}
+
+// ExistInvalidWordContext is ExistInvalidWord giving up once ctx is done
+func ExistInvalidWordContext(ctx context.Context, text string) (bool, error) {
+	matches, err := findCached(ctx, text)
+	return len(matches) > 0, err
+}
+
+// InvalidWords Return words defined in dictionary found in text
+func InvalidWords(text string) []string {
+	var words []string
+	matches, _ := findCached(context.Background(), text)
+	for _, m := range matches {
+		words = append(words, m.Entry.Word)
 	}
-	return false
+	return words
 }
 
 // ReplaceInvalidWords Replace words defineds in dictionary
 func ReplaceInvalidWords(text string) string {
-	segments := getSegments(text)
-	for _, seg := range segments {
-		token := seg.Token()
-		if token.Frequency() > 1 {
-			oldText := token.Text()
-			newText := strings.Repeat("*", utf8.RuneCountInString(oldText))
-			text = regexp.
-				MustCompile(fmt.Sprintf("(?i)%s", oldText)).
-				ReplaceAllLiteralString(text, newText)
-		}
+	replaced, _ := ReplaceInvalidWordsContext(context.Background(), text)
+	return replaced
+}
+
+// ReplaceInvalidWordsContext is ReplaceInvalidWords giving up once ctx is done
+func ReplaceInvalidWordsContext(ctx context.Context, text string) (string, error) {
+	matches, err := findCached(ctx, text)
+	if err != nil {
+		return "", err
+	}
+	return replace(ctx, text, matches), nil
+}
+
+// FilterContext is ReplaceInvalidWordsContext also returning the words found,
+// like DetectContext does, in a single pass over text
+func FilterContext(ctx context.Context, text string) (string, []Hit, error) {
+	matches, err := findCached(ctx, text)
+	if err != nil {
+		return "", nil, err
 	}
-	return text
+	return replace(ctx, text, matches), hitsOf(text, matches), nil
+}
+
+// replace replaces matches in text as configured in ctx
+func replace(ctx context.Context, text string, matches []Match) string {
+	return substitute(text, matches, replacementIn(ctx), FilterMode == PseudonymMode)
 }
 
-func getSegments(text string) []sego.Segment {
-	return segmenter.Segment([]byte(text))
+// substitute replaces matches in text by repl, or by their pseudonyms
+func substitute(text string, matches []Match, repl Replacement, pseudonym bool) string {
+	if len(matches) == 0 {
+		return text
+	}
+
+	// overlapping matches mask their union or are replaced by the text of
+	// the first one, or name all their words after each other in pseudonym
+	// mode
+	var result strings.Builder
+	result.Grow(len(text))
+	last := 0
+	for _, m := range matches {
+		if pseudonym {
+			if m.Start >= last {
+				result.WriteString(text[last:m.Start])
+			}
+			if m.End > last {
+				last = m.End
+			}
+			result.WriteString(Pseudonym(m.Entry))
+			continue
+		}
+		if len(repl.Text) > 0 {
+			if m.Start >= last {
+				result.WriteString(text[last:m.Start])
+				result.WriteString(repl.text(m.Entry))
+			}
+			if m.End > last {
+				last = m.End
+			}
+			continue
+		}
+		if m.End <= last {
+			continue
+		}
+		start := m.Start
+		if start < last {
+			start = last
+		}
+		result.WriteString(text[last:start])
+		for n := utf8.RuneCountInString(text[start:m.End]); n > 0; n-- {
+			result.WriteRune(repl.Mask)
+		}
+		last = m.End
+	}
+	result.WriteString(text[last:])
+	return result.String()
 }
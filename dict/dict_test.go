@@ -0,0 +1,75 @@
+package dict
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// use makes a dictionary of words the stable one until the test ends
+func use(tb testing.TB, words ...string) {
+	tb.Helper()
+	previous := dictionary()
+	entries := make([]Entry, len(words))
+	for i, w := range words {
+		entries[i] = Entry{Word: w}
+	}
+	if _, err := Replace(entries); err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { current.Store(previous) })
+}
+
+func TestFilterContext(t *testing.T) {
+	use(t, "坏词", "bad")
+	tests := []struct {
+		text     string
+		filtered string
+		words    []string
+	}{
+		{"", "", nil},
+		{"nothing here", "nothing here", nil},
+		{"一个坏词", "一个**", []string{"坏词"}},
+		{"bad and BAD", "*** and ***", []string{"bad", "bad"}},
+	}
+	for _, tt := range tests {
+		filtered, hits, err := FilterContext(context.Background(), tt.text)
+		if err != nil {
+			t.Fatalf("FilterContext(%q): %v", tt.text, err)
+		}
+		if filtered != tt.filtered {
+			t.Errorf("FilterContext(%q) = %q, want %q", tt.text, filtered, tt.filtered)
+		}
+		if len(hits) != len(tt.words) {
+			t.Fatalf("FilterContext(%q) found %v, want %v", tt.text, hits, tt.words)
+		}
+		for i, h := range hits {
+			if h.Word != tt.words[i] {
+				t.Errorf("FilterContext(%q) found %q at %d, want %q", tt.text, h.Word, i, tt.words[i])
+			}
+		}
+	}
+}
+
+// BenchmarkCheck compares /check matching text once per answer, as it did
+// with stats enabled, with the single pass of FilterContext
+func BenchmarkCheck(b *testing.B) {
+	use(b, "坏词", "bad", "敏感", "违禁品")
+	text := strings.Repeat("这是一段正常的文字，只有一个坏词在里面。", 20)
+	ctx := context.Background()
+
+	b.Run("separate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			ExistInvalidWordContext(ctx, text)
+			ReplaceInvalidWordsContext(ctx, text)
+			InvalidWords(text)
+		}
+	})
+	b.Run("single", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			FilterContext(ctx, text)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package dict
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sequenceNode is a node of the rune trie of a SequenceNormalizer
+type sequenceNode struct {
+	next map[rune]*sequenceNode
+	to   []rune
+	end  bool
+}
+
+// SequenceNormalizer replaces sequences of runes, the longest sequence wins.
+// Variation selectors not part of a sequence are swallowed by it, so "🐎" and
+// "🐎️" are the same.
+type SequenceNormalizer struct {
+	root sequenceNode
+}
+
+// NewSequenceNormalizer builds a normalizer replacing every key of mapping
+// by its value
+func NewSequenceNormalizer(mapping map[string]string) *SequenceNormalizer {
+	n := &SequenceNormalizer{}
+	for from, to := range mapping {
+		node := &n.root
+		for _, r := range from {
+			if node.next == nil {
+				node.next = make(map[rune]*sequenceNode)
+			}
+			child, ok := node.next[r]
+			if !ok {
+				child = &sequenceNode{}
+				node.next[r] = child
+			}
+			node = child
+		}
+		if node != &n.root {
+			node.to, node.end = []rune(to), true
+		}
+	}
+	return n
+}
+
+// Normalize implements Normalizer
+func (n *SequenceNormalizer) Normalize(chars []Char) []Char {
+	out := make([]Char, 0, len(chars))
+	for i := 0; i < len(chars); {
+		length, to := n.longest(chars[i:])
+		if length == 0 {
+			out = append(out, chars[i])
+			i++
+			continue
+		}
+
+		start, end := chars[i].Start, chars[i+length-1].End
+		for _, r := range to {
+			out = append(out, Char{r, start, end})
+		}
+		i += length
+	}
+	return out
+}
+
+// longest returns the number of chars and the replacement of the longest
+// sequence chars start with
+func (n *SequenceNormalizer) longest(chars []Char) (length int, to []rune) {
+	node := &n.root
+	for i, c := range chars {
+		child, ok := node.next[c.Rune]
+		if !ok {
+			if isVariationSelector(c.Rune) && node != &n.root {
+				if length == i {
+					length++
+				}
+				continue
+			}
+			break
+		}
+		node = child
+		if node.end {
+			length, to = i+1, node.to
+		}
+	}
+	return
+}
+
+func isVariationSelector(r rune) bool {
+	return r == '\ufe0e' || r == '\ufe0f'
+}
+
+// LoadEmoji reads a mapping file and registers the "emoji" normalizer, which
+// translates emoji and kaomoji to text like "🐎 马". The sequence and its text
+// are separated by a tab, or by the last space when the line has no tab.
+func LoadEmoji(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mapping := make(map[string]string)
+	scanner := bufio.NewScanner(skipBOM(f, &Report{}))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if len(strings.TrimSpace(text)) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		sep := strings.Index(text, "\t")
+		if sep < 0 {
+			sep = strings.LastIndex(text, " ")
+		}
+		if sep <= 0 {
+			return fmt.Errorf("%s:%d: invalid emoji mapping", path, line)
+		}
+		mapping[strings.TrimSpace(text[:sep])] = strings.TrimSpace(text[sep+1:])
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	RegisterNormalizer("emoji", NewSequenceNormalizer(mapping))
+	return nil
+}
@@ -0,0 +1,60 @@
+package dict
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// Encoding of dictionary files, one of "auto", "utf-8", "gbk" and "gb18030".
+// "auto" keeps valid utf-8 files as is and decodes the others as gb18030.
+// A file can override it with a secondary extension like "ads.gbk.txt".
+var Encoding = "auto"
+
+var encodings = map[string]encoding.Encoding{
+	"utf-8":   encoding.Nop,
+	"utf8":    encoding.Nop,
+	"gbk":     simplifiedchinese.GBK,
+	"gb18030": simplifiedchinese.GB18030,
+}
+
+// ValidEncoding tells if name can be used as Encoding
+func ValidEncoding(name string) bool {
+	_, ok := encodings[strings.ToLower(name)]
+	return ok || strings.EqualFold(name, "auto")
+}
+
+// decode converts the content of file to utf-8
+func decode(file string, r io.Reader) (io.Reader, error) {
+	name := strings.ToLower(Encoding)
+	if ext := filepath.Ext(strings.TrimSuffix(file, filepath.Ext(file))); len(ext) > 0 {
+		if _, ok := encodings[strings.ToLower(ext[1:])]; ok {
+			name = strings.ToLower(ext[1:])
+		}
+	}
+
+	if name != "auto" {
+		enc, ok := encodings[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown encoding %q", name)
+		}
+		return transform.NewReader(r, enc.NewDecoder()), nil
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if utf8.Valid(data) {
+		return bytes.NewReader(data), nil
+	}
+	return transform.NewReader(bytes.NewReader(data), simplifiedchinese.GB18030.NewDecoder()), nil
+}
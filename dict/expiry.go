@@ -0,0 +1,92 @@
+package dict
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// expiryLayouts are the accepted formats of expiry times in csv files, dates
+// are in the local time zone
+var expiryLayouts = []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"}
+
+// parseExpiry parses the expiry time of a csv record, nil if s is empty
+func parseExpiry(s string) (*time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return nil, nil
+	}
+	for _, layout := range expiryLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid expiry %q", s)
+}
+
+// expiredAt tells if e has expired at now
+func (e *Entry) expiredAt(now time.Time) bool {
+	return e.Expires != nil && !now.Before(*e.Expires)
+}
+
+// clone copies e, reading the flag set by Sweep atomically so that the
+// entries of a dictionary in use can be copied while it's swept
+func (e *Entry) clone() Entry {
+	return Entry{
+		ID:       e.ID,
+		Word:     e.Word,
+		Category: e.Category,
+		Severity: e.Severity,
+		Expires:  e.Expires,
+		Source:   e.Source,
+		expired:  atomic.LoadInt32(&e.expired),
+	}
+}
+
+// sweep deactivates the entries of d expired at now, it returns how many
+func (d *Dictionary) sweep(now time.Time) int {
+	n := 0
+	for _, i := range d.expiring {
+		e := &d.entries[i]
+		if atomic.LoadInt32(&e.expired) == 0 && e.expiredAt(now) {
+			atomic.StoreInt32(&e.expired, 1)
+			n++
+		}
+	}
+	return n
+}
+
+// Sweep deactivates the entries of the stable and canary dictionaries whose
+// expiry has passed, entries already expired when loaded never match
+func Sweep() {
+	now := time.Now()
+	for _, dst := range []struct {
+		version string
+		d       *Dictionary
+	}{{Stable, dictionary()}, {Canary, canary.Load().(*Dictionary)}} {
+		if dst.d == nil {
+			continue
+		}
+		if n := dst.d.sweep(now); n > 0 {
+			purgeCache()
+			log.Printf("%s词典%d个词过期", dst.version, n)
+		}
+	}
+}
+
+// SweepEvery sweeps every interval until stop is closed
+func SweepEvery(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			Sweep()
+		case <-stop:
+			return
+		}
+	}
+}
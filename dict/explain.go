@@ -0,0 +1,172 @@
+package dict
+
+import (
+	"context"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+// Reasons a word found in text is not a hit
+const (
+	ReasonDisabled   = "disabled"      // by Disable
+	ReasonSource     = "file-disabled" // by DisableSource
+	ReasonTooShort   = "too-short"     // than MinLength
+	ReasonExpired    = "expired"       // see Sweep
+	ReasonSkipped    = "skipped"       // between SkipOpen and SkipClose
+	ReasonMasked     = "masked"        // made of mask runes only, by a previous pass
+	ReasonOverlapped = "overlapped"    // by a hit winning under the Overlap policy
+)
+
+// Rejection is a word found in text which is not a hit, Reason tells why
+type Rejection struct {
+	Hit
+	Reason string `json:"reason"`
+}
+
+// Normalization is the text after a normalizer changed it
+type Normalization struct {
+	Normalizer string `json:"normalizer"`
+	Text       string `json:"text"`
+}
+
+// Explanation tells how a text was matched, for curators wondering why it
+// passed or not
+type Explanation struct {
+	Version        string          `json:"version"`
+	Revision       string          `json:"revision"`
+	Normalizations []Normalization `json:"normalizations"`
+	Hits           []Hit           `json:"hits"`
+	Rejected       []Rejection     `json:"rejected"`
+}
+
+// Explain matches text like Detect and also reports the normalizers which
+// changed it and the words found but rejected, with the reason
+func Explain(text string) Explanation {
+	e, _ := ExplainContext(context.Background(), text)
+	return e
+}
+
+// ExplainContext is Explain giving up once ctx is done
+func ExplainContext(ctx context.Context, text string) (Explanation, error) {
+	d := dictionaryFor(ctx, text)
+	matches, err := findIn(ctx, d, text)
+	if err != nil {
+		return Explanation{}, err
+	}
+
+	e := Explanation{
+		Version:        Stable,
+		Revision:       d.Revision(),
+		Normalizations: []Normalization{},
+		Hits:           hitsOf(text, matches),
+		Rejected:       []Rejection{},
+	}
+	if d == pinnedIn(ctx).canary {
+		e.Version = Canary
+	}
+
+	chars := textChars(text)
+	for i, n := range d.normalizers {
+		before := string(runesOf(chars))
+		chars = n.Normalize(chars)
+		if after := string(runesOf(chars)); after != before {
+			e.Normalizations = append(e.Normalizations, Normalization{d.names[i], after})
+		}
+	}
+
+	hit := make(map[Match]bool, len(matches))
+	for _, m := range matches {
+		hit[Match{Entry: m.Entry, Start: m.Start}] = true
+	}
+	mask := replacementIn(ctx).Mask
+	unskippedAt := make(map[int]bool)
+	for _, s := range unskipped(text) {
+		unskippedAt[s[0]] = true
+	}
+	for _, s := range spans(text) {
+		for _, m := range d.everyMatch(text[s[0]:s[1]]) {
+			m.Start += s[0]
+			m.End += s[0]
+			if hit[Match{Entry: m.Entry, Start: m.Start}] {
+				continue
+			}
+			var reason string
+			switch {
+			case MinLength > 1 && utf8.RuneCountInString(m.Entry.Word) < MinLength:
+				reason = ReasonTooShort
+			case atomic.LoadInt32(&m.Entry.expired) != 0:
+				reason = ReasonExpired
+			case sourceDisabled(m.Entry):
+				reason = ReasonSource
+			case !active(m.Entry):
+				reason = ReasonDisabled
+			case !unskippedAt[s[0]]:
+				reason = ReasonSkipped
+			case masked(text[m.Start:m.End], mask):
+				reason = ReasonMasked
+			default:
+				reason = ReasonOverlapped
+			}
+			for _, h := range hitsOf(text, []Match{m}) {
+				e.Rejected = append(e.Rejected, Rejection{h, reason})
+			}
+		}
+	}
+	return e, nil
+}
+
+// spans splits text into the ranges matched and the ones skipped, in order
+func spans(text string) [][2]int {
+	var result [][2]int
+	last := 0
+	for _, s := range unskipped(text) {
+		if s[0] > last {
+			result = append(result, [2]int{last, s[0]})
+		}
+		result = append(result, s)
+		last = s[1]
+	}
+	return result
+}
+
+// everyMatch returns every entry of d and every detector found in text at
+// every position, whether active or not
+func (d *Dictionary) everyMatch(text string) []Match {
+	normalized, chars := text, []Char(nil)
+	if len(d.normalizers) > 0 {
+		normalized, chars = normalize(text, d.normalizers)
+	}
+
+	var matches []Match
+	units := splitUnits(normalized)
+	for i := range units {
+		var id int
+		var err error
+		for j := i; j < len(units) && j-i < d.maxLen; j++ {
+			if id, err = d.trie.Jump(units[j].key, id); err != nil {
+				break
+			}
+			if v, err := d.trie.Value(id); err == nil {
+				matches = append(matches, Match{Entry: &d.entries[v], Start: units[i].start, End: units[j].end})
+			}
+		}
+		if len(d.patterns) > 0 {
+			d.eachPattern(normalized, units, i, func(*Entry) bool { return true }, func(n, value int) {
+				matches = append(matches, Match{Entry: &d.entries[value], Start: units[i].start, End: units[i+n-1].end})
+			})
+		}
+	}
+	if chars != nil {
+		offsets := charOffsets(chars)
+		for i := range matches {
+			matches[i].Start, matches[i].End = original(chars, offsets, matches[i].Start, matches[i].End)
+		}
+	}
+
+	for _, det := range detectors {
+		for _, loc := range det.find(text) {
+			matches = append(matches, Match{Entry: &det.entry, Start: loc[0], End: loc[1]})
+		}
+	}
+	return resolve(matches, AllMatches)
+}
@@ -0,0 +1,31 @@
+package dict
+
+import "testing"
+
+// FuzzMatch checks the invariants of matching, see checkMatching, on the
+// seeds below under go test and on generated texts with -fuzz=FuzzMatch
+func FuzzMatch(f *testing.F) {
+	use(f, fuzzWords...)
+	for _, seed := range []string{
+		"",
+		"bad",
+		"badword and bad",
+		"一个坏词语",
+		"测试封杀坏x",
+		"ｆｕｌｌ width",
+		"bid 坏了词",
+		"a1b2ab",
+		"\xff坏\xe8\xaf词",
+		"ba\xffd",
+		"\xed\xa0\x80bad",
+		"👨‍👩‍👧坏词é",
+		"坏词。bad！ab？",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		if _, err := checkMatching(text); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,172 @@
+package dict
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HistorySize is the number of loaded dictionaries kept to be diffed, the
+// entries of those no longer in use stay in memory. Unlimited if 0.
+var HistorySize = 10
+
+// Snapshot is a loaded dictionary, Revision identifies its content so the
+// same words always give the same revision
+type Snapshot struct {
+	Revision string    `json:"revision"`
+	Version  string    `json:"version"`
+	Loaded   time.Time `json:"loaded"`
+	Entries  int       `json:"entries"`
+
+	entries []Entry
+}
+
+// DiffResult is what changed between two snapshots, Changed are the entries
+// of to whose category or severity changed
+type DiffResult struct {
+	From    string  `json:"from"`
+	To      string  `json:"to"`
+	Added   []Entry `json:"added"`
+	Removed []Entry `json:"removed"`
+	Changed []Entry `json:"changed"`
+}
+
+var history struct {
+	sync.Mutex
+	snapshots []Snapshot // oldest first
+}
+
+// revision hashes entries
+func revision(entries []Entry) string {
+	h := sha256.New()
+	for _, e := range entries {
+		h.Write([]byte(e.Word))
+		h.Write([]byte{0})
+		h.Write([]byte(e.Category))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(e.Severity)))
+		h.Write([]byte{0})
+		if e.Expires != nil {
+			h.Write([]byte(e.Expires.UTC().Format(time.RFC3339)))
+			h.Write([]byte{0})
+		}
+		if len(e.Source) > 0 {
+			h.Write([]byte(e.Source))
+			h.Write([]byte{0})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// record adds d loaded as version to the history, a reload of the same
+// content only updates the time it was loaded
+func record(version string, d *Dictionary) {
+	history.Lock()
+	defer history.Unlock()
+
+	s := Snapshot{
+		Revision: d.Revision(),
+		Version:  version,
+		Loaded:   time.Now(),
+		Entries:  d.Len(),
+		entries:  d.Entries(),
+	}
+	for i, old := range history.snapshots {
+		if old.Revision == s.Revision {
+			history.snapshots = append(history.snapshots[:i], history.snapshots[i+1:]...)
+			break
+		}
+	}
+	history.snapshots = append(history.snapshots, s)
+	if n := len(history.snapshots) - HistorySize; n > 0 && HistorySize > 0 {
+		history.snapshots = append([]Snapshot(nil), history.snapshots[n:]...)
+	}
+}
+
+// Snapshots returns the loaded dictionaries kept, oldest first
+func Snapshots() []Snapshot {
+	history.Lock()
+	defer history.Unlock()
+	return append([]Snapshot(nil), history.snapshots...)
+}
+
+// snapshot returns the snapshot of a revision, "stable" and "canary" stand for
+// the dictionaries in use and "previous" for the stable one loaded before
+func snapshot(revision string) (Snapshot, error) {
+	switch revision {
+	case Stable, "":
+		revision = dictionary().Revision()
+	case Canary:
+		c := canary.Load().(*Dictionary)
+		if c == nil {
+			return Snapshot{}, fmt.Errorf("canary dictionary is not loaded")
+		}
+		revision = c.Revision()
+	case "previous":
+		current := dictionary().Revision()
+		history.Lock()
+		defer history.Unlock()
+		for i := len(history.snapshots) - 1; i >= 0; i-- {
+			if s := history.snapshots[i]; s.Version == Stable && s.Revision != current {
+				return s, nil
+			}
+		}
+		return Snapshot{}, fmt.Errorf("no previous dictionary")
+	}
+
+	history.Lock()
+	defer history.Unlock()
+	for _, s := range history.snapshots {
+		if s.Revision == revision {
+			return s, nil
+		}
+	}
+	return Snapshot{}, fmt.Errorf("unknown revision %q", revision)
+}
+
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Diff compares the snapshots of two revisions, see Snapshots
+func Diff(from, to string) (DiffResult, error) {
+	a, err := snapshot(from)
+	if err != nil {
+		return DiffResult{}, err
+	}
+	b, err := snapshot(to)
+	if err != nil {
+		return DiffResult{}, err
+	}
+
+	// entries are shared with dictionaries which may be in use, so they are
+	// cloned rather than copied
+	old := make(map[string]Entry, len(a.entries))
+	for i := range a.entries {
+		old[a.entries[i].Word] = a.entries[i].clone()
+	}
+	result := DiffResult{From: a.Revision, To: b.Revision, Added: []Entry{}, Removed: []Entry{}, Changed: []Entry{}}
+	for i := range b.entries {
+		e := b.entries[i].clone()
+		o, ok := old[e.Word]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, e)
+		case o.Category != e.Category || o.Severity != e.Severity || !sameExpiry(o.Expires, e.Expires):
+			result.Changed = append(result.Changed, e)
+		}
+		delete(old, e.Word)
+	}
+	for _, e := range old {
+		result.Removed = append(result.Removed, e)
+	}
+	sort.Slice(result.Removed, func(i, j int) bool { return result.Removed[i].Word < result.Removed[j].Word })
+	return result, nil
+}
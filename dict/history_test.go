@@ -0,0 +1,97 @@
+package dict
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	soon := time.Now().Add(10 * time.Millisecond)
+	previous := dictionary()
+	t.Cleanup(func() { current.Store(previous) })
+
+	from, err := Replace([]Entry{{Word: "bad"}, {Word: "坏词", Category: "广告"}, {Word: "old"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := Replace([]Entry{{Word: "bad"}, {Word: "坏词", Category: "政治", Expires: &soon}, {Word: "new", Expires: &past}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// diffing while the dictionary in use is swept
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		time.Sleep(time.Until(soon))
+		Sweep()
+	}()
+	var result DiffResult
+	for deadline := soon.Add(10 * time.Millisecond); time.Now().Before(deadline); {
+		if result, err = Diff(from.Revision(), to.Revision()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	words := func(entries []Entry) []string {
+		var words []string
+		for _, e := range entries {
+			words = append(words, e.Word)
+		}
+		return words
+	}
+	tests := []struct {
+		name string
+		got  []string
+		want []string
+	}{
+		{"added", words(result.Added), []string{"new"}},
+		{"removed", words(result.Removed), []string{"old"}},
+		{"changed", words(result.Changed), []string{"坏词"}},
+	}
+	for _, tt := range tests {
+		if len(tt.got) != len(tt.want) || (len(tt.got) > 0 && tt.got[0] != tt.want[0]) {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestHistoryFootprint(t *testing.T) {
+	previous := dictionary()
+	defer func(size int) { HistorySize = size }(HistorySize)
+	history.Lock()
+	kept := history.snapshots
+	history.snapshots = nil
+	history.Unlock()
+	t.Cleanup(func() {
+		current.Store(previous)
+		history.Lock()
+		history.snapshots = kept
+		history.Unlock()
+	})
+
+	HistorySize = 2
+	for _, words := range [][]string{{"one"}, {"two", "three"}, {"four"}} {
+		var entries []Entry
+		for _, w := range words {
+			entries = append(entries, Entry{Word: w})
+		}
+		if _, err := Replace(entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(Snapshots()); n != 2 {
+		t.Errorf("%d snapshots kept, want 2", n)
+	}
+
+	// only the snapshot no longer in use is counted as history
+	footprints := Footprints()
+	last := footprints[len(footprints)-1]
+	if last.Version != "history" || last.Entries != 2 || last.Bytes <= 0 {
+		t.Errorf("history footprint = %+v, want 2 entries", last)
+	}
+}
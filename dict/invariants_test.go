@@ -0,0 +1,70 @@
+//go:build !gofuzz
+// +build !gofuzz
+
+package dict
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// fuzzWords are matched against fuzzed texts, mixing latin runs, han,
+// wildcards and words sharing prefixes, none of them contains the mask rune
+var fuzzWords = []string{
+	"bad", "badword", "ab", "a1", "坏词", "坏", "词语", "测试封杀", "封杀", "x坏", "坏x", "ｆｕｌｌ", "b?d", "坏?词",
+}
+
+// checkMatching checks the invariants of matching text with the dictionary
+// in use, for FuzzMatch, and returns the number of hits. fuzz.go has a copy
+// for go-fuzz, which doesn't build tests, keep them in sync:
+// - nothing panics
+// - filtering keeps the number of runes and the filtered text is valid
+// - hits are ordered, don't overlap and stay within text
+// - sanitizing keeps the runes of valid text and the hits, in runes
+func checkMatching(text string) (int, error) {
+	filtered := ReplaceInvalidWords(text)
+	if utf8.RuneCountInString(filtered) != utf8.RuneCountInString(text) {
+		return 0, fmt.Errorf("filtering %q changed the number of runes: %q", text, filtered)
+	}
+	if ExistInvalidWord(filtered) {
+		return 0, fmt.Errorf("filtered %q into %q which is still invalid", text, filtered)
+	}
+
+	hits := Detect(text)
+	end := 0
+	for _, hit := range hits {
+		if hit.Start < end || hit.End <= hit.Start || hit.End > len(text) {
+			return 0, fmt.Errorf("invalid hit %+v in %q", hit, text)
+		}
+		if hit.RuneEnd-hit.RuneStart != utf8.RuneCountInString(text[hit.Start:hit.End]) {
+			return 0, fmt.Errorf("invalid rune offsets of %+v in %q", hit, text)
+		}
+		end = hit.End
+	}
+	if len(hits) != len(InvalidWords(text)) || (len(hits) > 0) != ExistInvalidWord(text) {
+		return 0, fmt.Errorf("detect and validate disagree on %q", text)
+	}
+	Sentences(text)
+
+	sanitized := Sanitize(text)
+	if !utf8.ValidString(sanitized) || utf8.RuneCountInString(sanitized) != utf8.RuneCountInString(text) {
+		return 0, fmt.Errorf("sanitized %q into %q", text, sanitized)
+	}
+	if utf8.ValidString(text) && sanitized != text {
+		return 0, fmt.Errorf("sanitizing changed valid %q into %q", text, sanitized)
+	}
+	if filtered := ReplaceInvalidWords(sanitized); !utf8.ValidString(filtered) {
+		return 0, fmt.Errorf("filtered sanitized %q into invalid %q", sanitized, filtered)
+	}
+	sanitizedHits := Detect(sanitized)
+	if len(sanitizedHits) != len(hits) {
+		return 0, fmt.Errorf("sanitizing %q changed hits %+v into %+v", text, hits, sanitizedHits)
+	}
+	for i, hit := range sanitizedHits {
+		if hit.Word != hits[i].Word || hit.RuneStart != hits[i].RuneStart || hit.RuneEnd != hits[i].RuneEnd {
+			return 0, fmt.Errorf("sanitizing %q changed hits %+v into %+v", text, hits, sanitizedHits)
+		}
+	}
+
+	return len(hits), nil
+}
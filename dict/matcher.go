@@ -0,0 +1,233 @@
+package dict
+
+import (
+	"context"
+	"fmt"
+)
+
+// Matcher is a dictionary with settings of its own, independent from the
+// package level dictionary and settings like SetNormalizers, Disable or
+// Mask, so that a process can hold several of them
+type Matcher struct {
+	d           *Dictionary
+	replacement Replacement
+}
+
+type options struct {
+	entries     []Entry
+	normalizers []Normalizer
+	names       []string
+	whitelist   []string
+	categories  map[string]bool
+	replacement Replacement
+	overlap     string
+	detectors   []*detector
+	prefilter   bool
+	parallel    int
+}
+
+// Option configures a Matcher built by New
+type Option func(o *options) error
+
+// WithEntries adds entries to the dictionary of the matcher
+func WithEntries(entries ...Entry) Option {
+	return func(o *options) error {
+		o.entries = append(o.entries, entries...)
+		return nil
+	}
+}
+
+// WithWords adds words with no metadata to the dictionary of the matcher
+func WithWords(words ...string) Option {
+	return func(o *options) error {
+		for _, word := range words {
+			o.entries = append(o.entries, Entry{Word: word})
+		}
+		return nil
+	}
+}
+
+// WithPath adds the entries of the dictionaries at dictPath, see Load. A
+// remote dictionary is always fetched, even if Load has loaded it already.
+func WithPath(dictPath string) Option {
+	return func(o *options) error {
+		var report Report
+		entries, err := read(dictPath, &report, false)
+		if err != nil {
+			return err
+		}
+		o.entries = append(o.entries, entries...)
+		return nil
+	}
+}
+
+// WithNormalizers sets the registered normalizers applied in order, none by
+// default
+func WithNormalizers(names ...string) Option {
+	return func(o *options) error {
+		registryMu.Lock()
+		defer registryMu.Unlock()
+
+		o.normalizers, o.names = nil, names
+		for _, name := range names {
+			n, ok := registry[name]
+			if !ok {
+				return fmt.Errorf("unknown normalizer %q", name)
+			}
+			o.normalizers = append(o.normalizers, n)
+		}
+		return nil
+	}
+}
+
+// WithWhitelist keeps words out of the dictionary even if they are among its
+// entries, they are compared once normalized
+func WithWhitelist(words ...string) Option {
+	return func(o *options) error {
+		o.whitelist = append(o.whitelist, words...)
+		return nil
+	}
+}
+
+// WithCategories keeps only the entries of categories, all by default
+func WithCategories(categories ...string) Option {
+	return func(o *options) error {
+		if o.categories == nil {
+			o.categories = make(map[string]bool)
+		}
+		for _, category := range categories {
+			o.categories[category] = true
+		}
+		return nil
+	}
+}
+
+// WithReplacement sets how Filter replaces the words it finds, every rune
+// is masked by * by default
+func WithReplacement(r Replacement) Option {
+	return func(o *options) error {
+		o.replacement = r
+		return nil
+	}
+}
+
+// WithOverlap sets the policy resolving overlapping words, LeftmostLongest
+// by default
+func WithOverlap(policy string) Option {
+	return func(o *options) error {
+		if err := checkOverlap(policy); err != nil {
+			return err
+		}
+		o.overlap = policy
+		return nil
+	}
+}
+
+// WithDetectors sets the builtin detectors run along the dictionary, see
+// SetDetectors, none by default
+func WithDetectors(names ...string) Option {
+	return func(o *options) error {
+		ds, err := builtins(names)
+		if err != nil {
+			return err
+		}
+		o.detectors = ds
+		return nil
+	}
+}
+
+// WithPrefilter sets whether texts sharing no rune with the first runes of
+// the words are skipped without matching, see Prefilter, enabled by default
+func WithPrefilter(enabled bool) Option {
+	return func(o *options) error {
+		o.prefilter = enabled
+		return nil
+	}
+}
+
+// WithParallelUnits sets the number of units of text from which it's
+// matched in parallel, see ParallelUnits, 0 disables parallel matching
+func WithParallelUnits(n int) Option {
+	return func(o *options) error {
+		if n < 0 {
+			return fmt.Errorf("invalid number of parallel units %d", n)
+		}
+		o.parallel = n
+		return nil
+	}
+}
+
+// New builds a matcher with opts, the entries are cleaned up like the ones
+// loaded by Load
+func New(opts ...Option) (*Matcher, error) {
+	o := options{overlap: LeftmostLongest, prefilter: true, parallel: defaultParallelUnits}
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	whitelisted := make(map[string]bool, len(o.whitelist))
+	for _, word := range o.whitelist {
+		whitelisted[string(joinUnits(splitUnits(normalizeString(word, o.normalizers))))] = true
+	}
+	var report Report
+	entries := o.entries[:0:0]
+	for _, e := range cleanEntries(o.entries, &report) {
+		if len(o.categories) > 0 && !o.categories[e.Category] {
+			continue
+		}
+		if whitelisted[string(joinUnits(splitUnits(normalizeString(e.Word, o.normalizers))))] {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	d := compile(entries, o.normalizers, o.names, o.overlap)
+	d.standalone = true
+	d.prefilter, d.parallel = o.prefilter, o.parallel
+	d.detectors = o.detectors
+	if o.replacement.Mask == 0 {
+		o.replacement.Mask = '*'
+	}
+	return &Matcher{d: d, replacement: o.replacement}, nil
+}
+
+// Dictionary returns the dictionary of m
+func (m *Matcher) Dictionary() *Dictionary {
+	return m.d
+}
+
+// match finds the words of m and the payloads of its detectors in text
+func (m *Matcher) match(ctx context.Context, text string) ([]Match, error) {
+	matches, err := m.d.MatchContext(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return m.d.detect(text, matches), nil
+}
+
+// Validate tells if text contains no word of m, or the error of ctx once
+// it's done
+func (m *Matcher) Validate(ctx context.Context, text string) (bool, error) {
+	matches, err := m.match(ctx, text)
+	return len(matches) == 0, err
+}
+
+// Filter replaces the words of m found in text as set by WithReplacement
+func (m *Matcher) Filter(ctx context.Context, text string) (string, error) {
+	matches, err := m.match(ctx, text)
+	if err != nil {
+		return "", err
+	}
+	return substitute(text, matches, m.replacement, false), nil
+}
+
+// Detect returns the words of m found in text with their offsets
+func (m *Matcher) Detect(ctx context.Context, text string) ([]Hit, error) {
+	matches, err := m.match(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return hitsOf(text, matches), nil
+}
@@ -0,0 +1,82 @@
+package dict
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// MaxSize is the max estimated memory footprint of a dictionary in bytes, a
+// reload building a larger one fails and the dictionary in use is kept.
+// Unlimited if 0.
+var MaxSize int64
+
+// Size estimates the memory used by d in bytes, the trie arrays and the
+// entries with their strings are counted
+func (d *Dictionary) Size() int64 {
+	var size int64
+	if d.trie != nil {
+		size += int64(cap(d.trie.Array)) * int64(unsafe.Sizeof(d.trie.Array[0]))
+		size += int64(cap(d.trie.Ninfos)) * int64(unsafe.Sizeof(d.trie.Ninfos[0]))
+		size += int64(cap(d.trie.Blocks)) * int64(unsafe.Sizeof(d.trie.Blocks[0]))
+	}
+	size += entriesSize(d.entries)
+	if d.first != nil {
+		size += int64(unsafe.Sizeof(*d.first)) + int64(len(d.first.other))*8
+	}
+	for _, ps := range d.patterns {
+		for _, p := range ps {
+			size += int64(cap(p.runes))*4 + int64(unsafe.Sizeof(p))
+		}
+	}
+	return size
+}
+
+// entriesSize estimates the memory used by entries with their strings
+func entriesSize(entries []Entry) int64 {
+	size := int64(cap(entries)) * int64(unsafe.Sizeof(Entry{}))
+	for _, e := range entries {
+		size += int64(len(e.Word) + len(e.Category))
+	}
+	return size
+}
+
+// Footprint is the memory used by a loaded version of dictionary
+type Footprint struct {
+	Version  string `json:"version"`
+	Revision string `json:"revision"`
+	Entries  int    `json:"entries"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// Footprints returns the footprint of the stable dictionary, the canary one
+// if it's loaded, and the entries of the snapshots kept for Diff of versions
+// no longer in use as "history"
+func Footprints() []Footprint {
+	d := dictionary()
+	footprints := []Footprint{{Stable, d.Revision(), d.Len(), d.Size()}}
+	inUse := map[string]bool{d.Revision(): true}
+	if c := canary.Load().(*Dictionary); c != nil {
+		footprints = append(footprints, Footprint{Canary, c.Revision(), c.Len(), c.Size()})
+		inUse[c.Revision()] = true
+	}
+
+	history := Footprint{Version: "history"}
+	for _, s := range Snapshots() {
+		if !inUse[s.Revision] {
+			history.Entries += len(s.entries)
+			history.Bytes += entriesSize(s.entries)
+		}
+	}
+	if history.Entries > 0 {
+		footprints = append(footprints, history)
+	}
+	return footprints
+}
+
+// checkSize fails if d is larger than MaxSize
+func checkSize(d *Dictionary) error {
+	if size := d.Size(); MaxSize > 0 && size > MaxSize {
+		return fmt.Errorf("字典占用%d字节，超过上限%d字节", size, MaxSize)
+	}
+	return nil
+}
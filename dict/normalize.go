@@ -0,0 +1,196 @@
+package dict
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Char is a rune of normalized text, Start and End are the byte range of the
+// original text it comes from
+type Char struct {
+	Rune       rune
+	Start, End int
+}
+
+// Normalizer rewrites text before matching, dictionary words go through the
+// same normalizers. Written chars must keep Start and End of the chars they
+// come from so matches can be reported on the original text.
+type Normalizer interface {
+	Normalize(chars []Char) []Char
+}
+
+// RuneNormalizer maps every rune to zero or more runes
+type RuneNormalizer func(r rune) []rune
+
+// Normalize implements Normalizer
+func (f RuneNormalizer) Normalize(chars []Char) []Char {
+	out := chars[:0:0]
+	for _, c := range chars {
+		for _, r := range f(c.Rune) {
+			out = append(out, Char{r, c.Start, c.End})
+		}
+	}
+	return out
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]Normalizer{
+		"lowercase":   RuneNormalizer(lowercase),
+		"width":       RuneNormalizer(foldWidth),
+		"confusables": RuneNormalizer(unconfuse),
+		"zero-width":  RuneNormalizer(stripZeroWidth),
+		"invisible":   RuneNormalizer(stripInvisible),
+	}
+
+	// normalizers used by dictionaries compiled from now on, with their
+	// names
+	normalizers     []Normalizer
+	normalizerNames []string
+)
+
+// RegisterNormalizer makes n available to SetNormalizers by name
+func RegisterNormalizer(name string, n Normalizer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = n
+}
+
+// NormalizerNames returns the names of registered normalizers
+func NormalizerNames() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetNormalizers sets the normalizers applied in order by dictionaries loaded
+// afterwards, it must be called before Load
+func SetNormalizers(names ...string) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	ns := make([]Normalizer, 0, len(names))
+	for _, name := range names {
+		n, ok := registry[name]
+		if !ok {
+			return fmt.Errorf("unknown normalizer %q", name)
+		}
+		ns = append(ns, n)
+	}
+	normalizers, normalizerNames = ns, names
+	return nil
+}
+
+// normalize runs text through ns, the normalized text and its chars are
+// returned
+func normalize(text string, ns []Normalizer) (string, []Char) {
+	chars := textChars(text)
+	for _, n := range ns {
+		chars = n.Normalize(chars)
+	}
+	return string(runesOf(chars)), chars
+}
+
+// textChars returns the chars of text before normalization
+func textChars(text string) []Char {
+	chars := make([]Char, 0, len(text))
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		chars = append(chars, Char{r, i, i + size})
+		i += size
+	}
+	return chars
+}
+
+func runesOf(chars []Char) []rune {
+	runes := make([]rune, len(chars))
+	for i, c := range chars {
+		runes[i] = c.Rune
+	}
+	return runes
+}
+
+// normalizeString runs s through ns
+func normalizeString(s string, ns []Normalizer) string {
+	if len(ns) == 0 {
+		return s
+	}
+	normalized, _ := normalize(s, ns)
+	return normalized
+}
+
+func lowercase(r rune) []rune {
+	return []rune{unicode.ToLower(r)}
+}
+
+// foldWidth turns fullwidth ascii variants and the ideographic space into
+// their halfwidth forms
+func foldWidth(r rune) []rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E:
+		return []rune{r - 0xFEE0}
+	case r == 0x3000:
+		return []rune{' '}
+	}
+	return []rune{r}
+}
+
+// confusables maps cyrillic and greek letters to the latin letters they look
+// like
+var confusables = map[rune]rune{
+	'а': 'a', 'е': 'e', 'ё': 'e', 'і': 'i', 'ј': 'j', 'к': 'k', 'о': 'o', 'р': 'p',
+	'с': 'c', 'у': 'y', 'х': 'x', 'ѕ': 's',
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P',
+	'С': 'C', 'Т': 'T', 'Х': 'X', 'У': 'Y', 'І': 'I', 'Ј': 'J', 'Ѕ': 'S',
+	'α': 'a', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't', 'υ': 'u',
+	'χ': 'x', 'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K',
+	'Μ': 'M', 'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	'ı': 'i', 'ℓ': 'l', 'ǀ': 'l',
+}
+
+func unconfuse(r rune) []rune {
+	if l, ok := confusables[r]; ok {
+		return []rune{l}
+	}
+	return []rune{r}
+}
+
+func stripZeroWidth(r rune) []rune {
+	switch r {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff':
+		return nil
+	}
+	return []rune{r}
+}
+
+// stripInvisible drops every rune that renders as nothing: format characters
+// (zero width spaces and joiners, bidi controls, soft hyphens, tags),
+// variation selectors, default ignorable fillers and the blank braille
+// pattern, so they can't be used to split words
+func stripInvisible(r rune) []rune {
+	if r == '\u2800' || unicode.In(r, unicode.Cf, unicode.Variation_Selector, unicode.Other_Default_Ignorable_Code_Point) {
+		return nil
+	}
+	return []rune{r}
+}
+
+// ParseNormalizers splits a comma separated list of normalizer names
+func ParseNormalizers(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,248 @@
+package dict
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// S3Config configures access to s3://bucket/key dictionaries, credentials
+// default to the standard AWS environment variables and requests are not
+// signed without them
+type S3Config struct {
+	Region       string
+	Endpoint     string // e.g. http://minio:9000, path style is used when set
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+}
+
+// S3 is the configuration of s3 dictionaries
+var S3 = S3Config{
+	Region:       os.Getenv("AWS_REGION"),
+	AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+	SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+}
+
+// GCSCredentials is the service account key file used to read
+// gs://bucket/object dictionaries, public objects are read without it
+var GCSCredentials = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+
+// newObjectRequest builds a GET request for a s3:// or gs:// url
+func newObjectRequest(u *url.URL) (*http.Request, error) {
+	if len(u.Host) == 0 || len(strings.TrimPrefix(u.Path, "/")) == 0 {
+		return nil, fmt.Errorf("invalid object url %q", u)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		return newS3Request(u.Host, strings.TrimPrefix(u.Path, "/"))
+	case "gs":
+		return newGCSRequest(u.Host, strings.TrimPrefix(u.Path, "/"))
+	}
+	return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+}
+
+func newS3Request(bucket, key string) (*http.Request, error) {
+	region := S3.Region
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+
+	var rawurl string
+	if len(S3.Endpoint) > 0 {
+		rawurl = strings.TrimSuffix(S3.Endpoint, "/") + "/" + bucket + "/" + s3Escape(key)
+	} else {
+		rawurl = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, s3Escape(key))
+	}
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(S3.AccessKey) > 0 {
+		signS3(req, region, time.Now().UTC())
+	}
+	return req, nil
+}
+
+// signS3 signs req with AWS signature version 4, the payload is unsigned
+func signS3(req *http.Request, region string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:UNSIGNED-PAYLOAD\n" +
+		"x-amz-date:" + amzDate + "\n"
+	if len(S3.SessionToken) > 0 {
+		req.Header.Set("X-Amz-Security-Token", S3.SessionToken)
+		headers = append(headers, "x-amz-security-token")
+		canonicalHeaders += "x-amz-security-token:" + S3.SessionToken + "\n"
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+S3.SecretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		S3.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape escapes every segment of key except the unreserved characters
+func s3Escape(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		var b []byte
+		for j := 0; j < len(s); j++ {
+			c := s[j]
+			if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+				c == '-' || c == '_' || c == '.' || c == '~' {
+				b = append(b, c)
+			} else {
+				b = append(b, fmt.Sprintf("%%%02X", c)...)
+			}
+		}
+		segments[i] = string(b)
+	}
+	return strings.Join(segments, "/")
+}
+
+func newGCSRequest(bucket, object string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", "https://storage.googleapis.com/"+bucket+"/"+s3Escape(object), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(GCSCredentials) > 0 {
+		token, err := gcsToken()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// cached access token of the gcs service account
+var gcsAccess struct {
+	sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// gcsToken exchanges a JWT signed by the service account for an access token
+func gcsToken() (string, error) {
+	gcsAccess.Lock()
+	defer gcsAccess.Unlock()
+	if time.Now().Before(gcsAccess.expires) {
+		return gcsAccess.token, nil
+	}
+
+	data, err := ioutil.ReadFile(GCSCredentials)
+	if err != nil {
+		return "", err
+	}
+	var account struct {
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &account); err != nil {
+		return "", err
+	}
+	if len(account.TokenURI) == 0 {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return "", errors.New("invalid private key in gcs credentials")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", errors.New("gcs private key is not a rsa key")
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   account.ClientEmail,
+		"scope": "https://www.googleapis.com/auth/devstorage.read_only",
+		"aud":   account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.PostForm(account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gcs token: unexpected status %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	gcsAccess.token = token.AccessToken
+	gcsAccess.expires = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return gcsAccess.token, nil
+}
@@ -0,0 +1,135 @@
+package dict
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Policies resolving words overlapping in text
+const (
+	// LeftmostLongest keeps the longest word starting at the leftmost
+	// position, then goes on after it
+	LeftmostLongest = "leftmost-longest"
+	// AllMatches keeps every word found, overlapping or not, Filter masks
+	// their union
+	AllMatches = "all"
+	// HighestSeverity keeps the words of highest severity first, then the
+	// leftmost and longest ones, among those overlapping each other
+	HighestSeverity = "highest-severity"
+)
+
+// Overlap is the policy resolving overlapping words, see SetOverlap
+var Overlap = LeftmostLongest
+
+// SetOverlap sets the policy resolving overlapping words, it must be called
+// before Load since dictionaries keep the policy they are compiled with
+func SetOverlap(policy string) error {
+	if err := checkOverlap(policy); err != nil {
+		return err
+	}
+	Overlap = policy
+	return nil
+}
+
+func checkOverlap(policy string) error {
+	switch policy {
+	case LeftmostLongest, AllMatches, HighestSeverity:
+		return nil
+	}
+	return fmt.Errorf("unknown overlap policy %q", policy)
+}
+
+// matchAll finds every active entry at every unit of text, for policies of d
+// other than LeftmostLongest
+func (d *Dictionary) matchAll(ctx context.Context, text string, units []unit) ([]Match, error) {
+	var matches []Match
+	for i, next := 0, checkEvery; i < len(units); i++ {
+		if i >= next {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			next = i + checkEvery
+		}
+
+		var id int
+		var err error
+		for j := i; j < len(units) && j-i < d.maxLen; j++ {
+			if id, err = d.trie.Jump(units[j].key, id); err != nil {
+				break
+			}
+			if v, err := d.trie.Value(id); err == nil && d.active(&d.entries[v]) {
+				matches = append(matches, Match{Entry: &d.entries[v], Start: units[i].start, End: units[j].end})
+			}
+		}
+		if len(d.patterns) > 0 {
+			matches = d.appendPatterns(matches, text, units, i)
+		}
+	}
+	return resolve(matches, d.overlap), nil
+}
+
+// resolve orders matches by position, longest first, and keeps the ones
+// winning under policy
+func resolve(matches []Match, policy string) []Match {
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	switch policy {
+	case AllMatches:
+		return matches
+	case HighestSeverity:
+		if len(matches) == 0 {
+			return matches
+		}
+		bySeverity := make([]int, len(matches))
+		for i := range bySeverity {
+			bySeverity[i] = i
+		}
+		sort.SliceStable(bySeverity, func(i, j int) bool {
+			return matches[bySeverity[i]].Entry.Severity > matches[bySeverity[j]].Entry.Severity
+		})
+
+		end := 0
+		for _, m := range matches {
+			if m.End > end {
+				end = m.End
+			}
+		}
+		covered := make([]bool, end)
+		kept := make([]bool, len(matches))
+	candidates:
+		for _, i := range bySeverity {
+			m := matches[i]
+			for p := m.Start; p < m.End; p++ {
+				if covered[p] {
+					continue candidates
+				}
+			}
+			for p := m.Start; p < m.End; p++ {
+				covered[p] = true
+			}
+			kept[i] = true
+		}
+		result := matches[:0]
+		for i, m := range matches {
+			if kept[i] {
+				result = append(result, m)
+			}
+		}
+		return result
+	default:
+		result := matches[:0]
+		for _, m := range matches {
+			if n := len(result); n > 0 && m.Start < result[n-1].End {
+				continue
+			}
+			result = append(result, m)
+		}
+		return result
+	}
+}
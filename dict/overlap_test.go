@@ -0,0 +1,111 @@
+package dict
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestOverlap(t *testing.T) {
+	entries := []Entry{
+		{Word: "中国人", Severity: 1},
+		{Word: "中国", Severity: 3},
+		{Word: "国人", Severity: 2},
+		{Word: "人民"},
+	}
+	tests := []struct {
+		policy   string
+		text     string
+		words    []string
+		filtered string
+	}{
+		{LeftmostLongest, "中国人民", []string{"中国人"}, "***民"},
+		{LeftmostLongest, "我是中国人", []string{"中国人"}, "我是***"},
+		{AllMatches, "中国人民", []string{"中国人", "中国", "国人", "人民"}, "****"},
+		{AllMatches, "国人", []string{"国人"}, "**"},
+		{HighestSeverity, "中国人民", []string{"中国", "人民"}, "****"},
+		{HighestSeverity, "中国人", []string{"中国"}, "**人"},
+		{HighestSeverity, "国人民", []string{"国人"}, "**民"},
+		{LeftmostLongest, "没有", nil, "没有"},
+		{AllMatches, "没有", nil, "没有"},
+		{HighestSeverity, "没有", nil, "没有"},
+	}
+	for _, tt := range tests {
+		m, err := New(WithEntries(entries...), WithOverlap(tt.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		hits, err := m.Detect(context.Background(), tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var words []string
+		for _, h := range hits {
+			words = append(words, h.Word)
+		}
+		if !reflect.DeepEqual(words, tt.words) {
+			t.Errorf("%s: Detect(%q) = %q, want %q", tt.policy, tt.text, words, tt.words)
+		}
+		filtered, err := m.Filter(context.Background(), tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filtered != tt.filtered {
+			t.Errorf("%s: Filter(%q) = %q, want %q", tt.policy, tt.text, filtered, tt.filtered)
+		}
+	}
+}
+
+func TestOverlapUnknownPolicy(t *testing.T) {
+	if err := SetOverlap("shortest"); err == nil {
+		t.Error("SetOverlap accepted an unknown policy")
+	}
+	if _, err := New(WithOverlap("shortest")); err == nil {
+		t.Error("New accepted an unknown policy")
+	}
+	if Overlap != LeftmostLongest {
+		t.Errorf("Overlap = %q after a failed SetOverlap", Overlap)
+	}
+}
+
+func TestMatcherDetectors(t *testing.T) {
+	defer func(ds []*detector) { detectors = ds }(detectors)
+	if err := SetDetectors("url"); err != nil {
+		t.Fatal(err)
+	}
+
+	text := "加qq号12345678"
+	entries := []Entry{{Word: "qq号", Severity: 2}}
+	tests := []struct {
+		name  string
+		opts  []Option
+		words []string
+	}{
+		// the detectors of SetDetectors don't apply to matchers
+		{"none", nil, []string{"qq号"}},
+		{"leftmost-longest", []Option{WithDetectors("qq")}, []string{"<qq>"}},
+		{"all", []Option{WithDetectors("qq"), WithOverlap(AllMatches)}, []string{"<qq>", "qq号"}},
+		{"highest-severity", []Option{WithDetectors("qq"), WithOverlap(HighestSeverity)}, []string{"qq号"}},
+	}
+	for _, tt := range tests {
+		m, err := New(append([]Option{WithEntries(entries...)}, tt.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hits, err := m.Detect(context.Background(), text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var words []string
+		for _, h := range hits {
+			words = append(words, h.Word)
+		}
+		if !reflect.DeepEqual(words, tt.words) {
+			t.Errorf("%s: Detect(%q) = %q, want %q", tt.name, text, words, tt.words)
+		}
+	}
+
+	if _, err := New(WithDetectors("fax")); err == nil {
+		t.Error("New accepted an unknown detector")
+	}
+}
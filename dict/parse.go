@@ -0,0 +1,188 @@
+package dict
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry is a word of dictionary with its metadata, ID is the stable id of the
+// rule referenced by hits, derived from the word unless it's given
+type Entry struct {
+	ID       string     `json:"id,omitempty"`
+	Word     string     `json:"word"`
+	Category string     `json:"category,omitempty"`
+	Severity int        `json:"severity,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"` // never if nil
+	Source   string     `json:"source,omitempty"`  // file the entry comes from, see DisableSource
+
+	expired int32 // set atomically once Expires has passed, see Sweep and clone
+}
+
+// Report summarizes how dictionary files were cleaned up while loading
+type Report struct {
+	Files      int
+	Entries    int
+	Normalized int // lines with a BOM, \r or surrounding whitespace stripped
+	Blank      int
+	Comments   int
+	Duplicates int
+}
+
+func (r Report) String() string {
+	return fmt.Sprintf("共%d个文件%d个词，规范化%d行，跳过空行%d、注释%d、重复%d",
+		r.Files, r.Entries, r.Normalized, r.Blank, r.Comments, r.Duplicates)
+}
+
+// parser reads entries from a dictionary file
+type parser func(r io.Reader, report *Report) ([]Entry, error)
+
+// parserFor picks a parser by the extension of file, plain text by default
+func parserFor(file string) parser {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return parseCSV
+	case ".json":
+		return parseJSON
+	default:
+		return parseText
+	}
+}
+
+// parseText reads one word per line, following fields are ignored for
+// compatibility with sego dictionaries ("word frequency pos"), blank lines
+// and lines starting with # are skipped
+func parseText(r io.Reader, report *Report) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case len(trimmed) == 0:
+			report.Blank++
+			continue
+		case strings.HasPrefix(trimmed, "#"):
+			report.Comments++
+			continue
+		case trimmed != line:
+			report.Normalized++
+		}
+		entries = append(entries, Entry{Word: strings.Fields(trimmed)[0]})
+	}
+	return entries, scanner.Err()
+}
+
+// parseCSV reads "word,category,severity,id,expires" records, the fields
+// after word are optional and a header row starting with "word" is skipped
+func parseCSV(r io.Reader, report *Report) ([]Entry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var entries []Entry
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 || (line == 1 && strings.EqualFold(record[0], "word")) {
+			continue
+		}
+
+		e := Entry{Word: record[0]}
+		if len(record) > 1 {
+			e.Category = strings.TrimSpace(record[1])
+		}
+		if len(record) > 2 {
+			if severity := strings.TrimSpace(record[2]); len(severity) > 0 {
+				if e.Severity, err = strconv.Atoi(severity); err != nil {
+					return nil, fmt.Errorf("line %d: invalid severity %q", line, severity)
+				}
+			}
+		}
+		if len(record) > 3 {
+			e.ID = strings.TrimSpace(record[3])
+		}
+		if len(record) > 4 {
+			if e.Expires, err = parseExpiry(record[4]); err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// parseJSON reads an array of entries, an entry can also be a plain string
+func parseJSON(r io.Reader, report *Report) ([]Entry, error) {
+	var raws []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raws); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(raws))
+	for i, raw := range raws {
+		var e Entry
+		var err error
+		if bytes.HasPrefix(bytes.TrimSpace(raw), []byte(`"`)) {
+			err = json.Unmarshal(raw, &e.Word)
+		} else {
+			err = json.Unmarshal(raw, &e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// skipBOM drops the utf-8 byte order mark at the beginning of r
+func skipBOM(r io.Reader, report *Report) io.Reader {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3)
+		report.Normalized++
+	}
+	return br
+}
+
+// cleanEntries trims words, drops empty ones and the duplicates, two words
+// are the same if they match the same text
+func cleanEntries(entries []Entry, report *Report) []Entry {
+	seen := make(map[string]bool, len(entries))
+	result := entries[:0]
+	for _, e := range entries {
+		word := strings.TrimSpace(e.Word)
+		if len(word) == 0 {
+			report.Blank++
+			continue
+		}
+		if word != e.Word {
+			e.Word = word
+			report.Normalized++
+		}
+
+		key := string(joinUnits(splitUnits(word)))
+		if seen[key] {
+			report.Duplicates++
+			continue
+		}
+		seen[key] = true
+		result = append(result, e)
+	}
+	report.Entries = len(result)
+	return result
+}
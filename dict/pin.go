@@ -0,0 +1,35 @@
+package dict
+
+import "context"
+
+// pinned are the dictionaries texts are matched against
+type pinned struct {
+	stable, canary *Dictionary
+}
+
+type pinKey struct{}
+
+// Pin returns a context under which texts are matched against the
+// dictionaries in use now even if they are reloaded meanwhile, so all the
+// texts of a batch get verdicts of the same dictionaries
+func Pin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinKey{}, pinnedIn(ctx))
+}
+
+// Revisions returns the revisions of the stable and canary dictionaries
+// pinned in ctx, or in use if none are. The canary one is empty unless texts
+// are routed to it.
+func Revisions(ctx context.Context) (stable, canary string) {
+	p := pinnedIn(ctx)
+	if CanaryPercent > 0 && p.canary != nil {
+		canary = p.canary.Revision()
+	}
+	return p.stable.Revision(), canary
+}
+
+func pinnedIn(ctx context.Context) pinned {
+	if p, ok := ctx.Value(pinKey{}).(pinned); ok {
+		return p
+	}
+	return pinned{dictionary(), canary.Load().(*Dictionary)}
+}
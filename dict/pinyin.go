@@ -0,0 +1,55 @@
+package dict
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// unitSeparator ends a unit without being part of any, so the syllables
+// written by the pinyin normalizer match one by one
+const unitSeparator = '\u001f'
+
+// LoadPinyin reads a mapping file of "字 zi" lines and registers the "pinyin"
+// normalizer, which replaces characters by their pinyin to catch homophones.
+// Only the first reading of a character is used and tones are kept as written.
+func LoadPinyin(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	table := make(map[rune][]rune)
+	scanner := bufio.NewScanner(skipBOM(f, &Report{}))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(fields[0])
+		if len(fields) < 2 || size != len(fields[0]) {
+			return fmt.Errorf("%s:%d: invalid pinyin mapping", path, line)
+		}
+		if _, ok := table[r]; !ok {
+			table[r] = []rune(strings.ToLower(fields[1]))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	RegisterNormalizer("pinyin", RuneNormalizer(func(r rune) []rune {
+		syllable, ok := table[r]
+		if !ok {
+			return []rune{r}
+		}
+		out := make([]rune, 0, len(syllable)+2)
+		out = append(out, unitSeparator)
+		out = append(out, syllable...)
+		return append(out, unitSeparator)
+	}))
+	return nil
+}
@@ -0,0 +1,48 @@
+package dict
+
+import "unicode/utf8"
+
+// Prefilter skips matching texts in which no rune can start an entry, like
+// most clean texts against dictionaries without latin words
+var Prefilter = true
+
+// runeSet is a set of runes, a bitmap over the basic multilingual plane
+type runeSet struct {
+	bmp   [(1 << 16) / 64]uint64
+	other map[rune]bool
+}
+
+func (s *runeSet) add(r rune) {
+	if r < 1<<16 {
+		s.bmp[r/64] |= 1 << uint(r%64)
+		return
+	}
+	if s.other == nil {
+		s.other = make(map[rune]bool)
+	}
+	s.other[r] = true
+}
+
+func (s *runeSet) has(r rune) bool {
+	if r < 1<<16 {
+		return s.bmp[r/64]&(1<<uint(r%64)) != 0
+	}
+	return s.other[r]
+}
+
+// addFirst adds the first rune of the key of an entry to the runes of d
+func (d *Dictionary) addFirst(key []byte) {
+	r, _ := utf8.DecodeRune(key)
+	d.first.add(r)
+}
+
+// mayMatch tells if a rune of normalized text can start an entry of d, texts
+// without any of them never match
+func (d *Dictionary) mayMatch(text string) bool {
+	for _, r := range text {
+		if d.first.has(lowerRune(r)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package dict
+
+import (
+	"io"
+	"log"
+	"time"
+)
+
+// progressInterval is the interval between logs of the progress of loading
+// a dictionary file
+const progressInterval = 5 * time.Second
+
+// progressReader logs how much of a large file has been read
+type progressReader struct {
+	r     io.Reader
+	file  string
+	read  int64
+	total int64
+	next  time.Time
+}
+
+func newProgressReader(r io.Reader, file string, total int64) *progressReader {
+	return &progressReader{r: r, file: file, total: total, next: time.Now().Add(progressInterval)}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if now := time.Now(); now.After(p.next) && p.total > 0 {
+		log.Printf("载入词典 %s：%d%%", p.file, p.read*100/p.total)
+		p.next = now.Add(progressInterval)
+	}
+	return n, err
+}
@@ -0,0 +1,54 @@
+package dict
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Modes replacing the words found by ReplaceInvalidWords
+const (
+	// MaskMode replaces every rune of words with Mask
+	MaskMode = "mask"
+	// PseudonymMode replaces words with their pseudonym, see Pseudonym
+	PseudonymMode = "pseudonym"
+)
+
+// FilterMode is the mode replacing words, see SetFilterMode
+var FilterMode = MaskMode
+
+var pseudonymKey []byte
+
+// SetFilterMode sets the mode replacing words, pseudonyms are keyed by key
+// which must not be empty then. It must be called before serving.
+func SetFilterMode(mode string, key []byte) error {
+	switch mode {
+	case MaskMode:
+	case PseudonymMode:
+		if len(key) == 0 {
+			return fmt.Errorf("pseudonym mode needs a key")
+		}
+		pseudonymKey = key
+	default:
+		return fmt.Errorf("unknown filter mode %q", mode)
+	}
+	FilterMode = mode
+	return nil
+}
+
+// Pseudonym returns the token replacing an entry in pseudonym mode: the first
+// 8 bytes of the HMAC-SHA256 of its word, in hex. The same word always gets
+// the same token under a key, so filtered texts can be counted by distinct
+// words without revealing them. Tokens are wrapped in SkipOpen and SkipClose
+// when skipping is enabled so that filtering again leaves them alone, in
+// brackets otherwise.
+func Pseudonym(e *Entry) string {
+	mac := hmac.New(sha256.New, pseudonymKey)
+	mac.Write([]byte(e.Word))
+	token := hex.EncodeToString(mac.Sum(nil)[:8])
+	if len(SkipOpen) == 0 || len(SkipClose) == 0 {
+		return "[" + token + "]"
+	}
+	return SkipOpen + token + SkipClose
+}
@@ -0,0 +1,125 @@
+package dict
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotModified is returned by Read when a remote dictionary has not
+// changed since it was last read
+var ErrNotModified = errors.New("not modified")
+
+// httpClient fetches remote dictionaries, through the proxy of the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables unless SetProxy
+// is called
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// SetProxy fetches remote dictionaries through the proxy at rawurl, like
+// http://proxy:3128, whatever the environment says. It must be called
+// before loading.
+func SetProxy(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+	if len(u.Scheme) == 0 || len(u.Host) == 0 {
+		return fmt.Errorf("invalid proxy %q", rawurl)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+	httpClient.Transport = transport
+	return nil
+}
+
+// validators of remote dictionaries for conditional requests
+var validators = struct {
+	sync.Mutex
+	m map[string]validator
+}{m: make(map[string]validator)}
+
+type validator struct {
+	etag         string
+	lastModified string
+}
+
+func isRemote(dictPath string) bool {
+	for _, scheme := range []string{"http://", "https://", "s3://", "gs://"} {
+		if strings.HasPrefix(dictPath, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch downloads the dictionary at rawurl. When conditional, ErrNotModified
+// is returned when it has not changed since the last conditional fetch,
+// other fetches leave the validators alone so they don't hide a change from
+// the next reload.
+func fetch(rawurl string, report *Report, conditional bool) ([]Entry, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	var req *http.Request
+	if u.Scheme == "http" || u.Scheme == "https" {
+		req, err = http.NewRequest("GET", rawurl, nil)
+	} else {
+		req, err = newObjectRequest(u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if conditional {
+		validators.Lock()
+		v := validators.m[rawurl]
+		validators.Unlock()
+		if len(v.etag) > 0 {
+			req.Header.Set("If-None-Match", v.etag)
+		}
+		if len(v.lastModified) > 0 {
+			req.Header.Set("If-Modified-Since", v.lastModified)
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, ErrNotModified
+	default:
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	log.Printf("载入词典 %s", rawurl)
+	r, err := decode(u.Path, resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := parserFor(u.Path)(skipBOM(r, report), report)
+	if err != nil {
+		return nil, err
+	}
+	report.Files++
+
+	if conditional {
+		validators.Lock()
+		validators.m[rawurl] = validator{
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+		}
+		validators.Unlock()
+	}
+	return entries, nil
+}
@@ -0,0 +1,35 @@
+package dict
+
+import (
+	"context"
+	"strings"
+)
+
+// Replacement is how ReplaceInvalidWordsContext replaces the words it finds
+// in mask mode, see ContextWithReplacement and WithReplacement
+type Replacement struct {
+	Mask rune   // replaces every rune of words, Mask if 0
+	Text string // replaces every word instead if not empty, {category} is replaced by its category
+}
+
+type replacementKey struct{}
+
+// ContextWithReplacement returns a copy of ctx in which words are replaced by
+// r instead of Mask, like for a tenant of its own
+func ContextWithReplacement(ctx context.Context, r Replacement) context.Context {
+	return context.WithValue(ctx, replacementKey{}, r)
+}
+
+// replacementIn returns the replacement of ctx, Mask by default
+func replacementIn(ctx context.Context) Replacement {
+	r, _ := ctx.Value(replacementKey{}).(Replacement)
+	if r.Mask == 0 {
+		r.Mask = Mask
+	}
+	return r
+}
+
+// text returns the text replacing e
+func (r Replacement) text(e *Entry) string {
+	return strings.Replace(r.Text, "{category}", e.Category, -1)
+}
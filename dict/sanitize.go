@@ -0,0 +1,28 @@
+package dict
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Sanitize replaces every byte of text which is not part of a valid utf-8
+// encoding by U+FFFD, like matching reads it, so hits keep their runes
+// offsets and filtered texts are valid utf-8
+func Sanitize(text string) string {
+	if utf8.ValidString(text) {
+		return text
+	}
+
+	var b strings.Builder
+	b.Grow(len(text) + 8)
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteRune(utf8.RuneError)
+		} else {
+			b.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
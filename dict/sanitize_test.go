@@ -0,0 +1,92 @@
+package dict
+
+import (
+	"context"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		sanitized string
+	}{
+		{"empty", "", ""},
+		{"valid", "一个坏词 bad", "一个坏词 bad"},
+		{"replacement rune kept", "a�b", "a�b"},
+		{"invalid byte", "a\xffb", "a�b"},
+		{"every byte of a truncated rune", "坏\xe8\xaf", "坏��"},
+		{"continuation bytes", "\x80\x80bad", "��bad"},
+		{"overlong encoding", "\xc0\xafbad", "��bad"},
+		{"surrogate", "\xed\xa0\x80", "���"},
+		{"beyond U+10FFFF", "\xf4\x90\x80\x80", "����"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sanitized := Sanitize(tt.text)
+			if sanitized != tt.sanitized {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.text, sanitized, tt.sanitized)
+			}
+			if utf8.RuneCountInString(sanitized) != utf8.RuneCountInString(tt.text) {
+				t.Errorf("Sanitize(%q) changed the number of runes", tt.text)
+			}
+		})
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	use(t, "坏词", "bad")
+	tests := []struct {
+		name  string
+		text  string
+		words []string
+	}{
+		{"invalid byte between words", "bad\xff坏词", []string{"bad", "坏词"}},
+		{"invalid byte inside a word", "ba\xffd 坏\xff词", nil},
+		{"truncated rune after a word", "坏词\xe5\x9d", []string{"坏词"}},
+		{"truncated rune before a word", "\xe5\x9d坏词", []string{"坏词"}},
+		{"only invalid bytes", "\xff\xfe\xfd", nil},
+		{"surrogate halves", "\xed\xa0\x80bad\xed\xbf\xbf", []string{"bad"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			filtered, hits, err := FilterContext(ctx, tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hits) != len(tt.words) {
+				t.Fatalf("FilterContext(%q) found %+v, want %q", tt.text, hits, tt.words)
+			}
+			for i, h := range hits {
+				if h.Word != tt.words[i] {
+					t.Errorf("FilterContext(%q) found %q at %d, want %q", tt.text, h.Word, i, tt.words[i])
+				}
+			}
+			if utf8.RuneCountInString(filtered) != utf8.RuneCountInString(tt.text) {
+				t.Errorf("FilterContext(%q) = %q, the number of runes changed", tt.text, filtered)
+			}
+
+			// sanitized first, hits are the same in runes and the filtered
+			// text is valid
+			sanitized := Sanitize(tt.text)
+			filtered, sanitizedHits, err := FilterContext(ctx, sanitized)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !utf8.ValidString(filtered) {
+				t.Errorf("FilterContext(%q) = %q, not valid utf-8", sanitized, filtered)
+			}
+			if len(sanitizedHits) != len(hits) {
+				t.Fatalf("sanitizing %q changed hits %+v into %+v", tt.text, hits, sanitizedHits)
+			}
+			for i, h := range sanitizedHits {
+				if h.RuneStart != hits[i].RuneStart || h.RuneEnd != hits[i].RuneEnd {
+					t.Errorf("sanitizing %q moved hit %+v to %+v", tt.text, hits[i], h)
+				}
+			}
+			Sentences(tt.text)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package dict
+
+import (
+	"context"
+	"strings"
+)
+
+// Mask replaces every rune of the words found by ReplaceInvalidWords, unless
+// the context gives another replacement
+var Mask = '*'
+
+// SkipOpen and SkipClose mark spans of text that are never matched, like the
+// output of a previous pass in a pipeline. Skipping is disabled when either
+// is empty, and an unclosed span is matched as usual.
+var SkipOpen, SkipClose string
+
+// find returns the matches of the dictionary and detectors in text outside
+// skipped spans, matches made of the mask runes of ctx only come from a
+// previous pass and are dropped
+func find(ctx context.Context, text string) ([]Match, error) {
+	return findIn(ctx, dictionaryFor(ctx, text), text)
+}
+
+func findIn(ctx context.Context, d *Dictionary, text string) ([]Match, error) {
+	mask := replacementIn(ctx).Mask
+	var matches []Match
+	for _, s := range unskipped(text) {
+		found, err := d.MatchContext(ctx, text[s[0]:s[1]])
+		if err != nil {
+			return nil, err
+		}
+		found = d.detect(text[s[0]:s[1]], found)
+		for _, m := range found {
+			m.Start += s[0]
+			m.End += s[0]
+			if !masked(text[m.Start:m.End], mask) {
+				matches = append(matches, m)
+			}
+		}
+	}
+	return matches, nil
+}
+
+// unskipped returns the [start, end) byte ranges of text to be matched
+func unskipped(text string) [][2]int {
+	if len(SkipOpen) == 0 || len(SkipClose) == 0 {
+		return [][2]int{{0, len(text)}}
+	}
+
+	var ranges [][2]int
+	start := 0
+	for {
+		open := strings.Index(text[start:], SkipOpen)
+		if open < 0 {
+			break
+		}
+		open += start
+		end := strings.Index(text[open+len(SkipOpen):], SkipClose)
+		if end < 0 {
+			break
+		}
+		end += open + len(SkipOpen) + len(SkipClose)
+
+		ranges = append(ranges, [2]int{start, open})
+		start = end
+	}
+	return append(ranges, [2]int{start, len(text)})
+}
+
+func masked(s string, mask rune) bool {
+	for _, r := range s {
+		if r != mask {
+			return false
+		}
+	}
+	return true
+}
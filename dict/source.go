@@ -0,0 +1,110 @@
+package dict
+
+import (
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// sourceOf names the entries loaded from dictPath, a file or a remote url,
+// by its name without directory and extension, files of the same name in
+// different directories share their source
+func sourceOf(dictPath string) string {
+	name := filepath.Base(dictPath)
+	if isRemote(dictPath) {
+		if u, err := url.Parse(dictPath); err == nil {
+			name = path.Base(u.Path)
+		}
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// setSource sets the source of entries which don't name one, like entries
+// of json files or pushed ones may
+func setSource(entries []Entry, source string) {
+	for i := range entries {
+		if len(entries[i].Source) == 0 {
+			entries[i].Source = source
+		}
+	}
+}
+
+// disabled sources, copied on write like disabled words
+var (
+	disabledSourcesMu sync.Mutex
+	disabledSources   atomic.Value
+)
+
+func init() {
+	disabledSources.Store(map[string]bool{})
+}
+
+// DisableSource stops the entries of source from matching until it's
+// enabled again, reloading dictionaries keeps it disabled
+func DisableSource(source string) {
+	setSourceDisabled(source, true)
+}
+
+// EnableSource lets the entries of a disabled source match again
+func EnableSource(source string) {
+	setSourceDisabled(source, false)
+}
+
+func setSourceDisabled(source string, off bool) {
+	disabledSourcesMu.Lock()
+	defer disabledSourcesMu.Unlock()
+
+	old := disabledSources.Load().(map[string]bool)
+	m := make(map[string]bool, len(old)+1)
+	for k := range old {
+		m[k] = true
+	}
+	if off {
+		m[source] = true
+	} else {
+		delete(m, source)
+	}
+	disabledSources.Store(m)
+	purgeCache()
+}
+
+// sourceDisabled tells if the source of e is disabled
+func sourceDisabled(e *Entry) bool {
+	m := disabledSources.Load().(map[string]bool)
+	return len(m) > 0 && m[e.Source]
+}
+
+// SourceCount is the number of entries of a source in a dictionary
+type SourceCount struct {
+	Source  string `json:"source"`
+	Words   int    `json:"words"`
+	Enabled bool   `json:"enabled"`
+}
+
+// Sources returns the number of entries of every source of the stable
+// dictionary, entries added by Add or without source are counted under "",
+// disabled sources missing from the dictionary are listed with no words
+func Sources() []SourceCount {
+	disabled := disabledSources.Load().(map[string]bool)
+	counts := make(map[string]int)
+	entries := dictionary().Entries()
+	for i := range entries {
+		counts[entries[i].Source]++
+	}
+	for source := range disabled {
+		if _, ok := counts[source]; !ok {
+			counts[source] = 0
+		}
+	}
+
+	sources := make([]SourceCount, 0, len(counts))
+	for source, n := range counts {
+		sources = append(sources, SourceCount{source, n, !disabled[source]})
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Source < sources[j].Source })
+	return sources
+}
@@ -0,0 +1,87 @@
+package dict
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// MinLength is the minimum number of runes of a word to match, shorter words
+// stay in dictionary but never match
+var MinLength int
+
+// disabled words, copied on write so matching never waits for a lock
+var (
+	disabledMu sync.Mutex
+	disabled   atomic.Value
+)
+
+func init() {
+	disabled.Store(map[string]bool{})
+}
+
+// Disable stops word from matching until it's enabled again, reloading
+// dictionaries keeps it disabled. The normalized word is returned.
+func Disable(word string) string {
+	return setDisabled(word, true)
+}
+
+// Enable lets a disabled word match again, the normalized word is returned
+func Enable(word string) string {
+	return setDisabled(word, false)
+}
+
+// Disabled returns the disabled words
+func Disabled() []string {
+	m := disabled.Load().(map[string]bool)
+	words := make([]string, 0, len(m))
+	for word := range m {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
+func setDisabled(word string, off bool) string {
+	key := string(joinUnits(splitUnits(word)))
+
+	disabledMu.Lock()
+	defer disabledMu.Unlock()
+
+	old := disabled.Load().(map[string]bool)
+	m := make(map[string]bool, len(old)+1)
+	for k := range old {
+		m[k] = true
+	}
+	if off {
+		m[key] = true
+	} else {
+		delete(m, key)
+	}
+	disabled.Store(m)
+	purgeCache()
+	return key
+}
+
+// active tells if the entry e of d can match, entries of dictionaries
+// compiled by New are only deactivated by their expiry, which no sweeper
+// checks for them
+func (d *Dictionary) active(e *Entry) bool {
+	if d.standalone {
+		return e.Expires == nil || time.Now().Before(*e.Expires)
+	}
+	return active(e)
+}
+
+// active tells if e can match
+func active(e *Entry) bool {
+	if MinLength > 1 && utf8.RuneCountInString(e.Word) < MinLength {
+		return false
+	}
+	if atomic.LoadInt32(&e.expired) != 0 || sourceDisabled(e) {
+		return false
+	}
+	return !disabled.Load().(map[string]bool)[e.Word]
+}
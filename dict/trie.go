@@ -0,0 +1,417 @@
+package dict
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/adamzy/cedar-go"
+)
+
+// Dictionary is an immutable set of entries compiled into a trie
+type Dictionary struct {
+	trie        *cedar.Cedar
+	entries     []Entry
+	maxLen      int                // longest entry in units
+	patterns    map[rune][]pattern // wildcard entries by their first rune
+	normalizers []Normalizer
+	names       []string // of normalizers
+	revision    string
+	expiring    []int       // entries with an expiry, see Sweep
+	first       *runeSet    // first runes of entries, see Prefilter
+	overlap     string      // policy resolving overlapping entries
+	standalone  bool        // compiled by New, package level settings don't apply
+	prefilter   bool        // Prefilter of a standalone dictionary
+	parallel    int         // ParallelUnits of a standalone dictionary
+	detectors   []*detector // of a standalone dictionary
+}
+
+// Match is an entry found in text, Start and End are byte offsets
+type Match struct {
+	Entry *Entry
+	Start int
+	End   int
+}
+
+// unit is the smallest matchable piece of text, a run of latin letters or
+// digits, or any other single rune
+type unit struct {
+	start, end int
+	key        []byte
+}
+
+// NewDictionary compiles entries with the normalizers set by SetNormalizers
+// and the Overlap policy, words which are the same once normalized keep the
+// first entry
+func NewDictionary(entries []Entry) *Dictionary {
+	return compile(entries, normalizers, normalizerNames, Overlap)
+}
+
+// compile compiles entries with ns named names, overlapping entries are
+// resolved by the policy overlap
+func compile(entries []Entry, ns []Normalizer, names []string, overlap string) *Dictionary {
+	d := &Dictionary{trie: cedar.New(), normalizers: ns, names: names, first: new(runeSet), overlap: overlap}
+	now := time.Now()
+	categories := make(interned)
+	for i := range entries {
+		e := entries[i].clone()
+		normalized := normalizeString(e.Word, d.normalizers)
+		if runes, ok := parsePattern(normalized); ok {
+			if !d.addPattern(runes, len(d.entries)) {
+				continue
+			}
+			d.first.add(runes[0])
+		} else {
+			units := splitUnits(normalized)
+			if len(units) == 0 {
+				continue
+			}
+
+			key := joinUnits(units)
+			if _, err := d.trie.Get(key); err == nil {
+				continue
+			}
+			d.trie.Insert(key, len(d.entries))
+			d.addFirst(key)
+			if len(units) > d.maxLen {
+				d.maxLen = len(units)
+			}
+		}
+
+		if key := joinUnits(splitUnits(e.Word)); string(key) != e.Word {
+			e.Word = string(key)
+		}
+		e.Category = categories.intern(e.Category)
+		if len(e.ID) == 0 {
+			e.ID = ruleID(e.Word)
+		}
+		if e.Expires != nil {
+			e.expired = 0
+			if e.expiredAt(now) {
+				e.expired = 1
+			}
+			d.expiring = append(d.expiring, len(d.entries))
+		}
+		d.entries = append(d.entries, e)
+	}
+	d.revision = revision(d.entries)
+	return d
+}
+
+// interned are strings shared by the entries of a dictionary, like its few
+// categories repeated by every entry, so hits point to the same memory
+type interned map[string]string
+
+func (in interned) intern(s string) string {
+	if v, ok := in[s]; ok {
+		return v
+	}
+	in[s] = s
+	return s
+}
+
+// ruleID derives the id of a rule from its word, so it's kept as long as the
+// word is in the dictionary whatever else changes
+func ruleID(word string) string {
+	h := fnv.New64a()
+	h.Write([]byte(word))
+	return fmt.Sprintf("w%016x", h.Sum64())
+}
+
+// Revision identifies the entries of dictionary
+func (d *Dictionary) Revision() string {
+	return d.revision
+}
+
+// Entries returns all entries of dictionary
+func (d *Dictionary) Entries() []Entry {
+	return d.entries
+}
+
+// Len returns the number of entries
+func (d *Dictionary) Len() int {
+	return len(d.entries)
+}
+
+// checkEvery is the number of units matched between checks of the context
+const checkEvery = 1024
+
+// Match finds entries in text, the longest entry starting at the leftmost
+// position wins and matches never overlap, unless d is compiled with another
+// Overlap policy
+func (d *Dictionary) Match(text string) []Match {
+	matches, _ := d.MatchContext(context.Background(), text)
+	return matches
+}
+
+// MatchContext is Match giving up with the error of ctx once it's done, no
+// matches are returned then
+func (d *Dictionary) MatchContext(ctx context.Context, text string) ([]Match, error) {
+	if len(d.normalizers) == 0 {
+		return d.match(ctx, text)
+	}
+
+	normalized, chars := normalize(text, d.normalizers)
+	offsets := charOffsets(chars)
+
+	// map matches back to text, merging the ones sharing original runes
+	matches, err := d.match(ctx, normalized)
+	if err != nil {
+		return nil, err
+	}
+	result := matches[:0]
+	for _, m := range matches {
+		m.Start, m.End = original(chars, offsets, m.Start, m.End)
+		if n := len(result); n > 0 && m.Start < result[n-1].End && d.overlap != AllMatches {
+			if m.End > result[n-1].End {
+				result[n-1].End = m.End
+			}
+			continue
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// charOffsets returns the byte offset of every char in the normalized text
+func charOffsets(chars []Char) []int {
+	offsets := make([]int, len(chars))
+	offset := 0
+	for i, c := range chars {
+		offsets[i] = offset
+		if size := utf8.RuneLen(c.Rune); size > 0 {
+			offset += size
+		} else {
+			offset += utf8.RuneLen(utf8.RuneError)
+		}
+	}
+	return offsets
+}
+
+// original maps the byte range [start, end) of normalized text back to the
+// text chars come from
+func original(chars []Char, offsets []int, start, end int) (int, int) {
+	first := sort.SearchInts(offsets, start)
+	last := sort.SearchInts(offsets, end) - 1
+	return chars[first].Start, chars[last].End
+}
+
+func (d *Dictionary) match(ctx context.Context, text string) ([]Match, error) {
+	prefilter, parallel := Prefilter, ParallelUnits
+	if d.standalone {
+		prefilter, parallel = d.prefilter, d.parallel
+	}
+	if prefilter && !d.mayMatch(text) {
+		return nil, nil
+	}
+	s := scratchPool.Get().(*scratch)
+	defer func() {
+		if cap(s.text) <= maxScratch {
+			scratchPool.Put(s)
+		}
+	}()
+	s.text = append(s.text[:0], text...)
+	s.units = appendUnits(s.units[:0], s.text)
+	units := s.units
+	if d.overlap != LeftmostLongest {
+		return d.matchAll(ctx, text, units)
+	}
+	longest := func(i int) (int, int) { return d.longestAt(text, units, i) }
+	if parallel > 0 && len(units) >= parallel && runtime.GOMAXPROCS(0) > 1 {
+		prefixes, err := d.longestAll(ctx, text, units)
+		if err != nil {
+			return nil, err
+		}
+		longest = func(i int) (int, int) { return prefixes[i].n, prefixes[i].value }
+	}
+
+	var matches []Match
+	for i, next := 0, checkEvery; i < len(units); {
+		if i >= next {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			next = i + checkEvery
+		}
+		if n, value := longest(i); n > 0 {
+			matches = append(matches, Match{
+				Entry: &d.entries[value],
+				Start: units[i].start,
+				End:   units[i+n-1].end,
+			})
+			i += n
+			continue
+		}
+		i++
+	}
+	return matches, nil
+}
+
+// ParallelUnits is the number of units of text from which the trie lookups
+// are split among GOMAXPROCS goroutines, 0 disables parallel matching
+var ParallelUnits = defaultParallelUnits
+
+const defaultParallelUnits = 64 << 10
+
+type prefix struct {
+	n, value int
+}
+
+// longestAll looks up the longest entry at every unit in parallel, the
+// longest entry at a unit doesn't depend on the units before it so chunks
+// need no overlap and the leftmost-longest pass over them is unchanged
+func (d *Dictionary) longestAll(ctx context.Context, text string, units []unit) ([]prefix, error) {
+	prefixes := make([]prefix, len(units))
+	workers := runtime.GOMAXPROCS(0)
+	size := (len(units) + workers - 1) / workers
+	errs := make(chan error, workers)
+	panics := make(chan interface{}, workers)
+	var wg sync.WaitGroup
+	for start := 0; start < len(units); start += size {
+		end := start + size
+		if end > len(units) {
+			end = len(units)
+		}
+
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			defer func() {
+				if p := recover(); p != nil {
+					panics <- p
+				}
+			}()
+			for i := start; i < end; i++ {
+				if (i-start)%checkEvery == 0 {
+					if err := ctx.Err(); err != nil {
+						errs <- err
+						return
+					}
+				}
+				prefixes[i].n, prefixes[i].value = d.longestAt(text, units, i)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+
+	// panics are raised again in the calling goroutine, where they can be
+	// recovered from
+	select {
+	case p := <-panics:
+		panic(p)
+	default:
+	}
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+		return prefixes, nil
+	}
+}
+
+// longestAt returns the number of units and the value of the longest active
+// entry at units[i] of text, a pattern only wins over a longer match
+func (d *Dictionary) longestAt(text string, units []unit, i int) (n, value int) {
+	n, value = d.longest(units[i:])
+	if len(d.patterns) > 0 {
+		if pn, pvalue := d.longestPattern(text, units, i); pn > n {
+			n, value = pn, pvalue
+		}
+	}
+	return
+}
+
+// longest returns the number of units and the value of the longest active
+// entry which is a prefix of units
+func (d *Dictionary) longest(units []unit) (n, value int) {
+	var id int
+	var err error
+	for i := 0; i < len(units) && i < d.maxLen; i++ {
+		id, err = d.trie.Jump(units[i].key, id)
+		if err != nil {
+			break
+		}
+		if v, err := d.trie.Value(id); err == nil && d.active(&d.entries[v]) {
+			n, value = i+1, v
+		}
+	}
+	return
+}
+
+// splitUnits splits text the same way sego does, latin letters and digits
+// are grouped and lowered, every other rune stands on its own
+func splitUnits(text string) []unit {
+	return appendUnits(make([]unit, 0, len(text)/3), []byte(text))
+}
+
+// appendUnits appends the units of text to units like splitUnits, their keys
+// are slices of text whose ascii letters are lowered in place, so a text is
+// split with no allocation once units has grown
+func appendUnits(units []unit, text []byte) []unit {
+	start := -1
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRune(text[i:])
+		if r == unitSeparator {
+			if start >= 0 {
+				units = append(units, unit{start, i, toLower(text[start:i])})
+				start = -1
+			}
+			i += size
+			continue
+		}
+		if size <= 2 && (unicode.IsLetter(r) || unicode.IsNumber(r)) {
+			if start < 0 {
+				start = i
+			}
+			i += size
+			continue
+		}
+		if start >= 0 {
+			units = append(units, unit{start, i, toLower(text[start:i])})
+			start = -1
+		}
+		units = append(units, unit{i, i + size, text[i : i+size]})
+		i += size
+	}
+	if start >= 0 {
+		units = append(units, unit{start, len(text), toLower(text[start:])})
+	}
+	return units
+}
+
+// scratch is the memory a text is split into while matching, it's pooled
+// since texts are split millions of times at high QPS
+type scratch struct {
+	text  []byte
+	units []unit
+}
+
+var scratchPool = sync.Pool{New: func() interface{} { return new(scratch) }}
+
+// maxScratch is the size from which the scratch of a text is left to the
+// garbage collector, so a few huge texts don't stay in the pool
+const maxScratch = 64 << 10
+
+func joinUnits(units []unit) []byte {
+	var key []byte
+	for _, u := range units {
+		key = append(key, u.key...)
+	}
+	return key
+}
+
+// toLower lowers ascii letters of b in place, like sego
+func toLower(b []byte) []byte {
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c - 'A' + 'a'
+		}
+	}
+	return b
+}
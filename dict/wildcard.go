@@ -0,0 +1,184 @@
+package dict
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// WildcardGap is the max number of characters matched by * in a wildcard
+// entry, which keeps matching bounded whatever the patterns
+var WildcardGap = 5
+
+// Wildcards of entries, a word is a pattern when it has one between two
+// literal characters, ? matches exactly one character and * up to
+// WildcardGap ones. A \ before ?, * or \ makes it literal.
+const (
+	anyChar rune = -1
+	anyRun  rune = -2
+)
+
+// pattern is a wildcard entry, matched rune by rune from the start of a unit
+// to the end of one
+type pattern struct {
+	runes []rune
+	value int
+}
+
+// parsePattern returns the runes of word with its wildcards, ok is false if
+// word has neither wildcards nor escapes and is matched by the trie. Words
+// starting or ending with a wildcard aren't patterns, they would match any
+// text around them.
+func parsePattern(word string) (runes []rune, ok bool) {
+	rs := []rune(word)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == unitSeparator:
+			continue
+		case r == '\\' && i+1 < len(rs) && (rs[i+1] == '?' || rs[i+1] == '*' || rs[i+1] == '\\'):
+			i++
+			r, ok = rs[i], true
+		case r == '?':
+			r, ok = anyChar, true
+		case r == '*':
+			r, ok = anyRun, true
+		}
+		runes = append(runes, lowerRune(r))
+	}
+	if !ok || runes[0] < 0 || runes[len(runes)-1] < 0 {
+		return nil, false
+	}
+	return runes, true
+}
+
+// addPattern adds runes as the pattern of the entry value, it's false if the
+// same pattern is already added
+func (d *Dictionary) addPattern(runes []rune, value int) bool {
+	for _, p := range d.patterns[runes[0]] {
+		if equalRunes(p.runes, runes) {
+			return false
+		}
+	}
+	if d.patterns == nil {
+		d.patterns = make(map[rune][]pattern)
+	}
+	d.patterns[runes[0]] = append(d.patterns[runes[0]], pattern{runes, value})
+	return true
+}
+
+// longestPattern returns the number of units and the value of the longest
+// active pattern matching text from units[i]
+func (d *Dictionary) longestPattern(text string, units []unit, i int) (n, value int) {
+	d.eachPattern(text, units, i, d.active, func(pn, pvalue int) {
+		if pn > n {
+			n, value = pn, pvalue
+		}
+	})
+	return
+}
+
+// appendPatterns appends every match of active patterns from units[i]
+func (d *Dictionary) appendPatterns(matches []Match, text string, units []unit, i int) []Match {
+	d.eachPattern(text, units, i, d.active, func(n, value int) {
+		matches = append(matches, Match{Entry: &d.entries[value], Start: units[i].start, End: units[i+n-1].end})
+	})
+	return matches
+}
+
+// eachPattern calls f with the number of units and the value of every
+// pattern kept by keep, like active, matching text from units[i]
+func (d *Dictionary) eachPattern(text string, units []unit, i int, keep func(e *Entry) bool, f func(n, value int)) {
+	r, _ := utf8.DecodeRuneInString(text[units[i].start:])
+	for _, p := range d.patterns[lowerRune(r)] {
+		if !keep(&d.entries[p.value]) {
+			continue
+		}
+		for _, end := range p.match(text, units[i].start) {
+			j := i + sort.Search(len(units)-i, func(j int) bool { return units[i+j].end >= end })
+			if j < len(units) && units[j].end == end {
+				f(j-i+1, p.value)
+			}
+		}
+	}
+}
+
+// match returns the offsets in text where p can end when it starts at
+// offset, the positions reachable after each rune of p are kept so no
+// backtracking is needed
+func (p pattern) match(text string, offset int) []int {
+	positions := []int{offset}
+	for _, want := range p.runes {
+		var next []int
+		for _, pos := range positions {
+			switch want {
+			case anyRun:
+				next = append(next, pos)
+				for n := 0; n < WildcardGap; n++ {
+					_, size := nextRune(text, pos)
+					if size == 0 {
+						break
+					}
+					pos += size
+					next = append(next, pos)
+				}
+			case anyChar:
+				if _, size := nextRune(text, pos); size > 0 {
+					next = append(next, pos+size)
+				}
+			default:
+				if r, size := nextRune(text, pos); size > 0 && lowerRune(r) == want {
+					next = append(next, pos+size)
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		positions = dedupe(next)
+	}
+	return positions
+}
+
+// nextRune decodes the rune of text at offset, skipping unit separators, size
+// is the bytes up to its end and 0 at the end of text
+func nextRune(text string, offset int) (r rune, size int) {
+	for i := offset; i < len(text); {
+		r, n := utf8.DecodeRuneInString(text[i:])
+		i += n
+		if r != unitSeparator {
+			return r, i - offset
+		}
+	}
+	return 0, 0
+}
+
+func equalRunes(a, b []rune) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func dedupe(positions []int) []int {
+	sort.Ints(positions)
+	result := positions[:1]
+	for _, pos := range positions[1:] {
+		if pos != result[len(result)-1] {
+			result = append(result, pos)
+		}
+	}
+	return result
+}
+
+// lowerRune lowers ascii letters only, like toLower
+func lowerRune(r rune) rune {
+	if r >= 'A' && r <= 'Z' {
+		return r - 'A' + 'a'
+	}
+	return r
+}
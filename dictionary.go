@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+)
+
+// configureDict applies the flags of matching and loading dictionaries to
+// the dict package
+func configureDict(c *config) error {
+	if !dict.ValidEncoding(c.dictEncoding) {
+		return fmt.Errorf("unknown encoding %q", c.dictEncoding)
+	}
+	dict.Encoding = c.dictEncoding
+	dict.MinLength = c.dictMinLength
+	dict.Prefilter = c.dictPrefilter
+	dict.MaxLength = c.dictMaxLength
+	dict.ParallelUnits = c.dictParallel
+	if err := dict.SetCacheSize(c.dictCache); err != nil {
+		return err
+	}
+	dict.WildcardGap = c.dictWildcardGap
+	dict.MaxSize = c.dictMaxSize
+	dict.HistorySize = c.dictHistory
+	if len(c.dictPinyin) > 0 {
+		if err := dict.LoadPinyin(c.dictPinyin); err != nil {
+			return err
+		}
+	}
+	if len(c.dictEmoji) > 0 {
+		if err := dict.LoadEmoji(c.dictEmoji); err != nil {
+			return err
+		}
+	}
+	if err := dict.SetNormalizers(dict.ParseNormalizers(c.dictNormalizers)...); err != nil {
+		return err
+	}
+	if err := dict.SetOverlap(c.dictOverlap); err != nil {
+		return err
+	}
+	if err := checkUTF8Policy(c.dictInvalidUTF8); err != nil {
+		return err
+	}
+	if err := dict.SetDetectors(detectorNames(c.dictDetectors)...); err != nil {
+		return err
+	}
+	dict.Delimiters = c.detectDelimiters
+	dict.ContextRunes = c.detectContext
+	dict.S3.Region = c.s3Region
+	dict.S3.Endpoint = c.s3Endpoint
+	dict.GCSCredentials = c.gcsCredentials
+	if len(c.dictProxy) > 0 {
+		if err := dict.SetProxy(c.dictProxy); err != nil {
+			return err
+		}
+	}
+	mask := []rune(c.filterMask)
+	if len(mask) != 1 {
+		return fmt.Errorf("mask %q must be a single character", c.filterMask)
+	}
+	dict.Mask = mask[0]
+	dict.SkipOpen, dict.SkipClose = c.filterSkipOpen, c.filterSkipClose
+	var key []byte
+	if len(c.filterKeyFile) > 0 {
+		var err error
+		if key, err = ioutil.ReadFile(c.filterKeyFile); err != nil {
+			return err
+		}
+		key = bytes.TrimSpace(key)
+	}
+	return dict.SetFilterMode(c.filterMode, key)
+}
+
+// detectorNames splits the -dict.detectors list
+func detectorNames(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// loadDictionaries loads the dictionaries in the background, requests are
+// rejected until it's done, then reloads them every -dict.refresh and sweeps
+// expired entries until lc stops them. The canary is loaded first as loading
+// the stable dictionary makes the service ready, texts are only routed to it
+// once it's loaded.
+func loadDictionaries(lc *lifecycle, c *config, logger log.Logger) {
+	if len(c.canaryPath) > 0 {
+		dict.CanaryPercent = c.canaryPercent
+	}
+	refresh := lc.Stop("dict refresh")
+	go func() {
+		if len(c.canaryPath) > 0 {
+			if err := dict.LoadCanary(c.canaryPath); err != nil {
+				logger.Log("component", "dict", "version", dict.Canary, "err", err)
+				os.Exit(1)
+			}
+		}
+		if err := dict.Load(c.dictPath); err != nil {
+			logger.Log("component", "dict", "err", err)
+			os.Exit(1)
+		}
+		logger.Log("component", "dict", "msg", "ready")
+
+		if c.dictRefresh > 0 {
+			go dict.Watch(c.dictPath, c.dictRefresh, refresh, func(err error) {
+				logger.Log("component", "dict", "err", err)
+			})
+			if len(c.canaryPath) > 0 {
+				go dict.WatchCanary(c.canaryPath, c.dictRefresh, refresh, func(err error) {
+					logger.Log("component", "dict", "version", dict.Canary, "err", err)
+				})
+			}
+		}
+	}()
+
+	if c.dictSweep > 0 {
+		go dict.SweepEvery(c.dictSweep, lc.Stop("dict sweeper"))
+	}
+}
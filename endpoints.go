@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/offenders"
+	"github.com/goofansu/wego/stats"
+)
+
+// newTextService returns the text service configured by c, alone and
+// wrapped in its logging, stats and utf-8 middlewares
+func newTextService(c *config, logger log.Logger, collector *stats.Collector) (textService, TextService, error) {
+	block := make(map[string]bool)
+	for _, category := range strings.Split(c.filterBlock, ",") {
+		if category = strings.TrimSpace(category); len(category) > 0 {
+			block[category] = true
+		}
+	}
+	sampling, err := parseSampling(c.logSample)
+	if err != nil {
+		return textService{}, nil, err
+	}
+
+	base := textService{strict: c.filterStrict, block: block}
+	var svc TextService = base
+	if c.sloLatency > 0 {
+		slowLogger := newRequestLogger(log.With(logger, "component", "slo"), strings.Split(c.logText, ","), nil)
+		svc = sloTextServiceMiddleware{slowLogger, c.sloLatency, collector, svc}
+	}
+	svc = statsTextServiceMiddleware{collector, svc}
+	svc = utf8TextServiceMiddleware{c.dictInvalidUTF8 == rejectUTF8, svc}
+	svc = loggingTextServiceMiddleware{newRequestLogger(logger, strings.Split(c.logText, ","), sampling), svc}
+	return base, svc, nil
+}
+
+// endpoints are the endpoints of the api, served over http and message
+// queues
+type endpoints struct {
+	validate      endpoint.Endpoint
+	filter        endpoint.Endpoint
+	check         endpoint.Endpoint
+	detect        endpoint.Endpoint
+	validateBatch endpoint.Endpoint
+	filterBatch   endpoint.Endpoint
+	detectBatch   endpoint.Endpoint
+
+	// limit rejects requests until the dictionary is loaded and bounds the
+	// texts matched at the same time
+	limit endpoint.Middleware
+}
+
+// makeEndpoints returns the endpoints of svc, scored, escalated and
+// localized as configured by c, and limited by workers
+func makeEndpoints(c *config, svc TextService, workers *limiter, logger log.Logger) (endpoints, error) {
+	limit := endpoint.Chain(readinessMiddleware(dict.Ready), timeoutMiddleware(c.limitTimeout), limitingMiddleware(workers))
+
+	score := func(next endpoint.Endpoint) endpoint.Endpoint { return next }
+	if len(c.scoreURL) > 0 {
+		if c.scoreFallback != "block" && c.scoreFallback != "allow" {
+			return endpoints{}, fmt.Errorf("unknown score fallback %q", c.scoreFallback)
+		}
+		score = scoringMiddleware(scorer{
+			url:       c.scoreURL,
+			client:    &http.Client{},
+			severity:  c.scoreSeverity,
+			threshold: c.scoreThreshold,
+			allow:     c.scoreFallback == "allow",
+		}, c.scoreTimeout, logger)
+	}
+
+	var tracker offenders.Tracker
+	if len(c.offendersRedis) > 0 {
+		tracker = offenders.NewRedis(c.offendersRedis, c.offendersWindow)
+	} else {
+		tracker = offenders.NewMemory(c.offendersWindow)
+	}
+	escalate := offendersMiddleware(tracker, offenders.Escalation{
+		Warn:  c.offendersWarn,
+		Mute:  c.offendersMute,
+		Block: c.offendersBlock,
+	}, logger)
+	localize := localizingMiddleware()
+	batch := batchLimitMiddleware(c.limitBatch)
+
+	return endpoints{
+		validate:      limit(localize(escalate(score(makeValidateEndpoint(svc))))),
+		filter:        limit(localize(escalate(score(makeFilterEndpoint(svc))))),
+		check:         limit(localize(escalate(score(makeCheckEndpoint(svc))))),
+		detect:        limit(localize(escalate(score(makeDetectEndpoint(svc))))),
+		validateBatch: batch(limit(makeValidateBatchEndpoint(svc))),
+		filterBatch:   batch(limit(makeFilterBatchEndpoint(svc))),
+		detectBatch:   batch(limit(makeDetectBatchEndpoint(svc))),
+		limit:         limit,
+	}, nil
+}
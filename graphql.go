@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/goofansu/wego/dict"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// graphqlSchema is the schema served by the /graphql endpoint, it can also
+// be queried by introspection
+const graphqlSchema = `schema {
+  query: Query
+  mutation: Mutation
+}
+
+type Query {
+  validate(message: String!, user: String): Boolean!
+  filter(message: String!, user: String): String!
+  detect(message: String!, user: String): [Hit!]!
+  disabledWords: [String!]!
+}
+
+type Mutation {
+  enableWord(word: String!): Word!
+  disableWord(word: String!): Word!
+}
+
+type Hit {
+  rule: String!
+  word: String!
+  category: String
+  severity: Int
+  start: Int!
+  end: Int!
+  runeStart: Int!
+  runeEnd: Int!
+}
+
+type Word {
+  word: String!
+  enabled: Boolean!
+}
+`
+
+// errMutationGET is returned by mutations sent with GET, which may be
+// replayed by anything following links
+var errMutationGET = errors.New("mutations must be sent with POST")
+
+type graphqlGETKey struct{}
+
+type graphqlHandler struct {
+	schema *graphql.Schema
+}
+
+// newGraphQLHandler serves queries with the endpoints of the http api so
+// they go through the same middlewares
+func newGraphQLHandler(validate, filter, detect, word, disabled endpoint.Endpoint) http.Handler {
+	return graphqlHandler{graphql.MustParseSchema(graphqlSchema, &graphqlResolver{validate, filter, detect, word, disabled})}
+}
+
+func (h graphqlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	ctx := r.Context()
+	switch r.Method {
+	case "GET":
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); len(v) > 0 {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeGraphQL(w, http.StatusBadRequest, &graphql.Response{Errors: graphqlErrors(err)})
+				return
+			}
+		}
+		ctx = context.WithValue(ctx, graphqlGETKey{}, true)
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeGraphQL(w, http.StatusBadRequest, &graphql.Response{Errors: graphqlErrors(err)})
+			return
+		}
+	}
+
+	resp := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	code := http.StatusOK
+	if resp.Data == nil && len(resp.Errors) > 0 {
+		code = http.StatusBadRequest // the query was not executed
+	}
+	writeGraphQL(w, code, resp)
+}
+
+func graphqlErrors(err error) []*gqlerrors.QueryError {
+	return []*gqlerrors.QueryError{{Message: err.Error()}}
+}
+
+func writeGraphQL(w http.ResponseWriter, code int, resp *graphql.Response) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// graphqlResolver resolves the root fields of graphqlSchema
+type graphqlResolver struct {
+	validate, filter, detect, word, disabled endpoint.Endpoint
+}
+
+type graphqlMessageArgs struct {
+	Message string
+	User    *string
+}
+
+func (a graphqlMessageArgs) user() string {
+	if a.User == nil {
+		return ""
+	}
+	return *a.User
+}
+
+type graphqlWordArgs struct {
+	Word string
+}
+
+func (r *graphqlResolver) Validate(ctx context.Context, args graphqlMessageArgs) (bool, error) {
+	resp, err := r.validate(ctx, validateRequest{args.Message, args.user()})
+	if err != nil {
+		return false, err
+	}
+	return resp.(validateResponse).V, nil
+}
+
+func (r *graphqlResolver) Filter(ctx context.Context, args graphqlMessageArgs) (string, error) {
+	resp, err := r.filter(ctx, filterRequest{args.Message, args.user()})
+	if err != nil {
+		return "", err
+	}
+	return resp.(filterResponse).V, nil
+}
+
+func (r *graphqlResolver) Detect(ctx context.Context, args graphqlMessageArgs) ([]graphqlHit, error) {
+	resp, err := r.detect(ctx, detectRequest{S: args.Message, User: args.user()})
+	if err != nil {
+		return nil, err
+	}
+	hits := resp.(detectResponse).V
+	result := make([]graphqlHit, len(hits))
+	for i, hit := range hits {
+		result[i] = graphqlHit{hit}
+	}
+	return result, nil
+}
+
+func (r *graphqlResolver) DisabledWords(ctx context.Context) ([]string, error) {
+	resp, err := r.disabled(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.(disabledResponse).V, nil
+}
+
+func (r *graphqlResolver) EnableWord(ctx context.Context, args graphqlWordArgs) (graphqlWord, error) {
+	return r.setWord(ctx, args.Word, true)
+}
+
+func (r *graphqlResolver) DisableWord(ctx context.Context, args graphqlWordArgs) (graphqlWord, error) {
+	return r.setWord(ctx, args.Word, false)
+}
+
+func (r *graphqlResolver) setWord(ctx context.Context, word string, enabled bool) (graphqlWord, error) {
+	if get, _ := ctx.Value(graphqlGETKey{}).(bool); get {
+		return graphqlWord{}, errMutationGET
+	}
+	resp, err := r.word(ctx, wordRequest{word, enabled})
+	if err != nil {
+		return graphqlWord{}, err
+	}
+	return graphqlWord{resp.(wordResponse)}, nil
+}
+
+// graphqlHit resolves the fields of Hit, empty category and severity are
+// null like they are omitted from the json of the http api
+type graphqlHit struct {
+	hit dict.Hit
+}
+
+func (h graphqlHit) Rule() string { return h.hit.Rule }
+func (h graphqlHit) Word() string { return h.hit.Word }
+
+func (h graphqlHit) Category() *string {
+	if len(h.hit.Category) == 0 {
+		return nil
+	}
+	return &h.hit.Category
+}
+
+func (h graphqlHit) Severity() *int32 {
+	if h.hit.Severity == 0 {
+		return nil
+	}
+	severity := int32(h.hit.Severity)
+	return &severity
+}
+
+func (h graphqlHit) Start() int32     { return int32(h.hit.Start) }
+func (h graphqlHit) End() int32       { return int32(h.hit.End) }
+func (h graphqlHit) RuneStart() int32 { return int32(h.hit.RuneStart) }
+func (h graphqlHit) RuneEnd() int32   { return int32(h.hit.RuneEnd) }
+
+// graphqlWord resolves the fields of Word
+type graphqlWord struct {
+	word wordResponse
+}
+
+func (w graphqlWord) Word() string  { return w.word.Word }
+func (w graphqlWord) Enabled() bool { return w.word.Enabled }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestGraphQL(t *testing.T) {
+	loadTestDict(t, "bad")
+	s := newTestServer(t, "-admin.insecure")
+	tests := []struct {
+		name   string
+		method string
+		query  string
+		code   int
+		want   string
+	}{
+		{"query", "POST", `{"query": "{ validate(message: \"so bad\") detect(message: \"so bad\") { word runeStart category } }"}`,
+			http.StatusOK, `{"data":{"validate":false,"detect":[{"word":"bad","runeStart":3,"category":null}]}}`},
+		{"aliases and variables", "POST", `{"query": "query Q($m: String!) { a: filter(message: $m) b: validate(message: \"fine\") }", "variables": {"m": "so bad"}}`,
+			http.StatusOK, `{"data":{"a":"so ***","b":true}}`},
+		{"introspection", "POST", `{"query": "{ __schema { mutationType { name } } __type(name: \"Hit\") { fields { name } } }"}`,
+			http.StatusOK, `{"name":"Mutation"}`},
+		{"get", "GET", `{ validate(message: "fine") }`,
+			http.StatusOK, `{"data":{"validate":true}}`},
+		{"mutation with get", "GET", `mutation { disableWord(word: "bad") { enabled } }`,
+			http.StatusOK, errMutationGET.Error()},
+		{"unknown field", "POST", `{"query": "{ nope }"}`,
+			http.StatusBadRequest, `Cannot query field \"nope\"`},
+		{"missing argument", "POST", `{"query": "{ validate }"}`,
+			http.StatusBadRequest, `"errors"`},
+		{"syntax error", "POST", `{"query": "{ validate("}`,
+			http.StatusBadRequest, `"errors"`},
+		{"invalid body", "POST", `{`,
+			http.StatusBadRequest, `"errors"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r *http.Request
+			if tt.method == "GET" {
+				r = httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(tt.query), nil)
+			} else {
+				r = httptest.NewRequest("POST", "/graphql", strings.NewReader(tt.query))
+				r.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			s.api.ServeHTTP(w, r)
+			if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("%d %s, want %d %s", w.Code, w.Body, tt.code, tt.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("GET", "/graphql/schema", nil)
+	w := httptest.NewRecorder()
+	s.api.ServeHTTP(w, r)
+	if w.Body.String() != graphqlSchema {
+		t.Errorf("/graphql/schema = %s", w.Body)
+	}
+}
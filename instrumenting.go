@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/stats"
+)
+
+type hitsKey struct{}
+
+// withHits returns a copy of ctx in which textService records the words it
+// finds, so middlewares counting them don't match the text again. The
+// recorder of ctx is reused and emptied if it has one, so middlewares of
+// endpoints see the words recorded for the middlewares of the service.
+func withHits(ctx context.Context) (context.Context, *[]dict.Hit) {
+	if hits, ok := ctx.Value(hitsKey{}).(*[]dict.Hit); ok {
+		*hits = nil
+		return ctx, hits
+	}
+	hits := new([]dict.Hit)
+	return context.WithValue(ctx, hitsKey{}, hits), hits
+}
+
+// recordHits records hits in ctx if it's made by withHits
+func recordHits(ctx context.Context, hits []dict.Hit) {
+	if recorded, ok := ctx.Value(hitsKey{}).(*[]dict.Hit); ok {
+		*recorded = hits
+	}
+}
+
+// recordedHits returns the hits recorded in ctx, ok is false if ctx isn't
+// made by withHits
+func recordedHits(ctx context.Context) (hits []dict.Hit, ok bool) {
+	recorded, ok := ctx.Value(hitsKey{}).(*[]dict.Hit)
+	if !ok {
+		return nil, false
+	}
+	return *recorded, true
+}
+
+// words returns the words of hits
+func words(hits []dict.Hit) []string {
+	words := make([]string, len(hits))
+	for i, hit := range hits {
+		words[i] = hit.Word
+	}
+	return words
+}
+
+// statsTextServiceMiddleware records requests, their latency by text length
+// and matched words, requests given up because their context is done are not
+// recorded
+type statsTextServiceMiddleware struct {
+	stats *stats.Collector
+	next  TextService
+}
+
+func (mw statsTextServiceMiddleware) Validate(ctx context.Context, text string) (bool, error) {
+	begin := time.Now()
+	ctx, hits := withHits(ctx)
+	v, err := mw.next.Validate(ctx, text)
+	if err != nil {
+		return v, err
+	}
+	mw.stats.Latency("validate", utf8.RuneCountInString(text), time.Since(begin))
+	mw.stats.Request("validate", dict.Version(text), !v)
+	mw.stats.Match(words(*hits)...)
+	return v, nil
+}
+
+func (mw statsTextServiceMiddleware) Filter(ctx context.Context, text string) (string, error) {
+	begin := time.Now()
+	ctx, hits := withHits(ctx)
+	filtered, err := mw.next.Filter(ctx, text)
+	if err != nil && ctx.Err() != nil {
+		return filtered, err
+	}
+	mw.stats.Latency("filter", utf8.RuneCountInString(text), time.Since(begin))
+	mw.stats.Request("filter", dict.Version(text), len(*hits) > 0)
+	mw.stats.Match(words(*hits)...)
+	return filtered, err
+}
+
+func (mw statsTextServiceMiddleware) Check(ctx context.Context, text string) (bool, string, error) {
+	begin := time.Now()
+	ctx, hits := withHits(ctx)
+	valid, filtered, err := mw.next.Check(ctx, text)
+	if err != nil && ctx.Err() != nil {
+		return valid, filtered, err
+	}
+	mw.stats.Latency("check", utf8.RuneCountInString(text), time.Since(begin))
+	mw.stats.Request("check", dict.Version(text), len(*hits) > 0)
+	mw.stats.Match(words(*hits)...)
+	return valid, filtered, err
+}
+
+func (mw statsTextServiceMiddleware) Detect(ctx context.Context, text string) ([]dict.Hit, error) {
+	begin := time.Now()
+	hits, err := mw.next.Detect(ctx, text)
+	if err != nil {
+		return hits, err
+	}
+	mw.stats.Latency("detect", utf8.RuneCountInString(text), time.Since(begin))
+	mw.stats.Request("detect", dict.Version(text), len(hits) > 0)
+	for _, hit := range hits {
+		mw.stats.Match(hit.Word)
+	}
+	return hits, nil
+}
+
+func (mw statsTextServiceMiddleware) Sentences(ctx context.Context, text string) ([]dict.Sentence, error) {
+	begin := time.Now()
+	sentences, err := mw.next.Sentences(ctx, text)
+	if err != nil {
+		return sentences, err
+	}
+	mw.stats.Latency("detect", utf8.RuneCountInString(text), time.Since(begin))
+	hit := false
+	for _, sentence := range sentences {
+		for _, h := range sentence.Hits {
+			mw.stats.Match(h.Word)
+			hit = true
+		}
+	}
+	mw.stats.Request("detect", dict.Version(text), hit)
+	return sentences, nil
+}
+
+// flushStats restores the match statistics of -stats.file, then writes
+// them to it every -stats.interval, and once more on shutdown
+func flushStats(lc *lifecycle, c *config, collector *stats.Collector, logger log.Logger) {
+	if len(c.statsFile) == 0 {
+		return
+	}
+	if err := collector.Restore(c.statsFile); err != nil {
+		logger.Log("component", "stats", "err", err)
+	}
+	lc.OnStop("stats", func(context.Context) error {
+		return collector.Flush(c.statsFile)
+	})
+	go collector.FlushEvery(c.statsFile, c.statsInterval, lc.Stop("stats flusher"), func(err error) {
+		logger.Log("component", "stats", "err", err)
+	})
+}
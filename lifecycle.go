@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// lifecycle runs the long running components of the service until a signal
+// or one of them fails, then stops them in the reverse order of their
+// registration, each within timeout, so that listeners are drained before
+// what they feed is flushed
+type lifecycle struct {
+	logger  log.Logger
+	timeout time.Duration
+	errc    chan error
+	hooks   []stopHook
+}
+
+type stopHook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+func newLifecycle(logger log.Logger, timeout time.Duration) *lifecycle {
+	return &lifecycle{
+		logger:  log.With(logger, "component", "lifecycle"),
+		timeout: timeout,
+		errc:    make(chan error, 1),
+	}
+}
+
+// Go runs run in a goroutine, the service is shut down when it returns an
+// error
+func (l *lifecycle) Go(name string, run func() error) {
+	go func() {
+		if err := run(); err != nil {
+			select {
+			case l.errc <- fmt.Errorf("%s: %v", name, err):
+			default:
+			}
+		}
+	}()
+}
+
+// OnStop registers stop to be called on shutdown before the ones registered
+// earlier
+func (l *lifecycle) OnStop(name string, stop func(ctx context.Context) error) {
+	l.hooks = append(l.hooks, stopHook{name, stop})
+}
+
+// Stop returns a channel closed when the component name is stopped, for
+// components taking a stop channel
+func (l *lifecycle) Stop(name string) <-chan struct{} {
+	c := make(chan struct{})
+	l.OnStop(name, func(context.Context) error {
+		close(c)
+		return nil
+	})
+	return c
+}
+
+// Run blocks until SIGINT, SIGTERM or a component failure, then runs the
+// stop hooks and returns the cause of the shutdown
+func (l *lifecycle) Run() error {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	var cause error
+	select {
+	case s := <-c:
+		cause = fmt.Errorf("%s", s)
+	case cause = <-l.errc:
+	}
+	signal.Stop(c)
+	l.logger.Log("msg", "shutdown", "cause", cause)
+
+	for i := len(l.hooks) - 1; i >= 0; i-- {
+		h := l.hooks[i]
+		begin := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+		err := h.stop(ctx)
+		cancel()
+		l.logger.Log("msg", "stopped", "name", h.name, "took", time.Since(begin), "err", err)
+	}
+	return cause
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// overloadedError is returned when the queue of matcher executions is full,
+// it's encoded as 503 with a Retry-After header
+type overloadedError struct {
+	retryAfter time.Duration
+}
+
+func (e overloadedError) Error() string {
+	return "server is overloaded"
+}
+
+func (e overloadedError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e overloadedError) Headers() http.Header {
+	seconds := int((e.retryAfter + time.Second - 1) / time.Second)
+	return http.Header{"Retry-After": []string{strconv.Itoa(seconds)}}
+}
+
+func (e overloadedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"error": e.Error()})
+}
+
+// notReadyError is returned until the dictionary is loaded, it's encoded as
+// 503 with a Retry-After header
+type notReadyError struct{}
+
+func (e notReadyError) Error() string {
+	return "dictionary is loading"
+}
+
+func (e notReadyError) StatusCode() int {
+	return http.StatusServiceUnavailable
+}
+
+func (e notReadyError) Headers() http.Header {
+	return http.Header{"Retry-After": []string{"1"}}
+}
+
+func (e notReadyError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"error": e.Error()})
+}
+
+// readinessMiddleware rejects requests until ready returns true
+func readinessMiddleware(ready func() bool) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if !ready() {
+				return nil, notReadyError{}
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// statusClientClosedRequest is the non standard status of requests the client
+// gave up, the client never sees it but it shows up in access logs
+const statusClientClosedRequest = 499
+
+// abortedError is returned when matching is given up because the client went
+// away or the deadline passed, nothing of the text is returned then
+type abortedError struct {
+	err error
+}
+
+func (e abortedError) Error() string {
+	if e.err == context.DeadlineExceeded {
+		return "matching timed out"
+	}
+	return "request canceled"
+}
+
+func (e abortedError) StatusCode() int {
+	if e.err == context.DeadlineExceeded {
+		return http.StatusGatewayTimeout
+	}
+	return statusClientClosedRequest
+}
+
+func (e abortedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error   string `json:"error"`
+		Partial bool   `json:"partial"`
+	}{e.Error(), false})
+}
+
+// timeoutMiddleware gives up requests taking longer than timeout, no deadline
+// is set if timeout is 0, and reports requests given up as abortedError
+func timeoutMiddleware(timeout time.Duration) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			response, err := next(ctx, request)
+			if err != nil && (err == context.Canceled || err == context.DeadlineExceeded) {
+				return nil, abortedError{err}
+			}
+			return response, err
+		}
+	}
+}
+
+// limiter runs at most workers requests at the same time, up to queue
+// requests wait for a free worker and the others are rejected. Both can be
+// changed while serving, workers <= 0 doesn't limit requests.
+type limiter struct {
+	mu               sync.Mutex
+	workers, queue   int
+	running, waiting int
+	freed            chan struct{} // closed when a worker may be free
+	retryAfter       time.Duration
+}
+
+func newLimiter(workers, queue int, retryAfter time.Duration) *limiter {
+	return &limiter{workers: workers, queue: queue, freed: make(chan struct{}), retryAfter: retryAfter}
+}
+
+// Resize changes the number of workers and queued requests, requests already
+// running above the new number of workers are let finish
+func (l *limiter) Resize(workers, queue int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.workers, l.queue = workers, queue
+	l.wake()
+}
+
+// Size returns the number of workers and queued requests
+func (l *limiter) Size() (workers, queue int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.workers, l.queue
+}
+
+func (l *limiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.workers <= 0 || l.running < l.workers {
+		l.running++
+		return nil
+	}
+	if l.waiting >= l.queue {
+		return overloadedError{l.retryAfter}
+	}
+
+	l.waiting++
+	defer func() { l.waiting-- }()
+	for l.workers > 0 && l.running >= l.workers {
+		freed := l.freed
+		l.mu.Unlock()
+		select {
+		case <-freed:
+			l.mu.Lock()
+		case <-ctx.Done():
+			l.mu.Lock()
+			return ctx.Err()
+		}
+	}
+	l.running++
+	return nil
+}
+
+func (l *limiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+	l.wake()
+}
+
+// wake lets waiting requests check for a free worker, l.mu must be held
+func (l *limiter) wake() {
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// limitingMiddleware runs requests within the workers of l
+func limitingMiddleware(l *limiter) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if err := l.acquire(ctx); err != nil {
+				return nil, err
+			}
+			defer l.release()
+
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(1, 1, 1500*time.Millisecond)
+	ctx := context.Background()
+	if err := l.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a request waits for the worker, the next one finds the queue full
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(ctx) }()
+	for {
+		l.mu.Lock()
+		waiting := l.waiting
+		l.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	err := l.acquire(ctx)
+	overloaded, ok := err.(overloadedError)
+	if !ok {
+		t.Fatalf("acquire with a full queue = %v, want overloadedError", err)
+	}
+	if got := overloaded.Headers().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+
+	l.release()
+	if err := <-acquired; err != nil {
+		t.Fatalf("waiting acquire = %v", err)
+	}
+
+	// waiting requests give up with their context
+	canceled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(canceled); err != context.DeadlineExceeded {
+		t.Errorf("acquire with a deadline = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// more workers let waiting requests run
+	go func() { acquired <- l.acquire(ctx) }()
+	time.Sleep(5 * time.Millisecond)
+	l.Resize(2, 1)
+	if err := <-acquired; err != nil {
+		t.Fatalf("acquire after Resize = %v", err)
+	}
+	if workers, queue := l.Size(); workers != 2 || queue != 1 {
+		t.Errorf("Size = %d, %d, want 2, 1", workers, queue)
+	}
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	slow := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	_, err := timeoutMiddleware(time.Millisecond)(slow)(context.Background(), nil)
+	aborted, ok := err.(abortedError)
+	if !ok || aborted.StatusCode() != http.StatusGatewayTimeout {
+		t.Errorf("timed out request = %v, want 504", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = timeoutMiddleware(0)(slow)(ctx, nil)
+	if aborted, ok := err.(abortedError); !ok || aborted.StatusCode() != statusClientClosedRequest {
+		t.Errorf("canceled request = %v, want 499", err)
+	}
+}
+
+func TestBodyLimits(t *testing.T) {
+	loadTestDict(t, "bad")
+	s := newTestServer(t, "-admin.insecure", "-limit.body", "64", "-limit.batch", "2", "-admin.max-body", "64")
+	large := strings.Repeat("x", 100)
+
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	fw, _ := mw.CreateFormFile("file", "words.txt")
+	fw.Write([]byte(large))
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		code        int
+		want        string
+	}{
+		{"batch", "/validate/batch", "application/x-www-form-urlencoded", "message=ok&message=bad", http.StatusOK, `"result":[true,false]`},
+		{"batch too long", "/filter/batch", "application/x-www-form-urlencoded", "message=a&message=b&message=c", http.StatusBadRequest, "batches are limited to 2"},
+		{"batch too large", "/detect/batch", "application/x-www-form-urlencoded", "message=" + large, http.StatusRequestEntityTooLarge, "larger than 64 bytes"},
+		{"push too large", "/admin/dict/push", "application/json", `[{"word": "` + large + `"}]`, http.StatusRequestEntityTooLarge, "larger than 64 bytes"},
+		{"bulk too large", "/admin/words/bulk", "application/json", `[{"word": "` + large + `"}]`, http.StatusRequestEntityTooLarge, "larger than 64 bytes"},
+		{"bulk file too large", "/admin/words/bulk", mw.FormDataContentType(), multipartBody.String(), http.StatusRequestEntityTooLarge, "larger than 64 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			s.api.ServeHTTP(w, r)
+			if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("%d %s, want %d %s", w.Code, strings.TrimSpace(w.Body.String()), tt.code, tt.want)
+			}
+		})
+	}
+
+	// batches are limited over message queues too
+	_, err := s.endpoints.validateBatch(context.Background(), batchRequest{[]string{"a", "b", "c"}})
+	if _, ok := err.(badRequestError); !ok {
+		t.Errorf("batch of 3 messages = %v, want badRequestError", err)
+	}
+}
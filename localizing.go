@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/goofansu/wego/offenders"
+)
+
+// blockedMessage is the key of the message of texts with blocked words, the
+// other keys are the verdicts of offenders
+const blockedMessage = "blocked"
+
+// defaultLanguage is the language of messages when requests ask for none
+// with messages, or aren't made over http
+var defaultLanguage = "zh"
+
+// messages are the verdict messages shown to end users by language
+var messages = map[string]map[string]string{
+	"zh": {
+		blockedMessage:  "内容包含敏感词",
+		offenders.Warn:  "内容包含敏感词，请文明发言",
+		offenders.Mute:  "您多次发布违规内容，已被禁言",
+		offenders.Block: "您多次发布违规内容，账号已被封禁",
+	},
+	"en": {
+		blockedMessage:  "Message contains prohibited content",
+		offenders.Warn:  "Message contains prohibited content, please mind your language",
+		offenders.Mute:  "You have been muted for repeatedly posting prohibited content",
+		offenders.Block: "Your account has been blocked for repeatedly posting prohibited content",
+	},
+}
+
+// loadMessages merges the messages of file, a json object of messages by
+// key by language, into the builtin ones
+func loadMessages(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var custom map[string]map[string]string
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return err
+	}
+	for lang, ms := range custom {
+		lang = strings.ToLower(lang)
+		if messages[lang] == nil {
+			messages[lang] = make(map[string]string)
+		}
+		for key, message := range ms {
+			messages[lang][key] = message
+		}
+	}
+	return nil
+}
+
+type languageKey struct{}
+
+// languageHandler puts the language of verdict messages into the context of
+// requests, see language
+func languageHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), languageKey{}, language(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// language picks the language of the lang query parameter, or the preferred
+// one of Accept-Language which has messages, defaultLanguage otherwise
+func language(r *http.Request) string {
+	if lang, ok := supported(r.URL.Query().Get("lang")); ok {
+		return lang
+	}
+
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, field := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		parts := strings.Split(field, ";")
+		w := weighted{strings.TrimSpace(parts[0]), 1}
+		for _, param := range parts[1:] {
+			if s := strings.TrimSpace(param); strings.HasPrefix(s, "q=") {
+				if q, err := strconv.ParseFloat(s[2:], 64); err == nil {
+					w.q = q
+				}
+			}
+		}
+		if w.q > 0 {
+			tags = append(tags, w)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, w := range tags {
+		if lang, ok := supported(w.tag); ok {
+			return lang
+		}
+	}
+	return defaultLanguage
+}
+
+// supported returns the language with messages matching tag, zh-CN falls
+// back to zh
+func supported(tag string) (string, bool) {
+	tag = strings.ToLower(tag)
+	for len(tag) > 0 {
+		if messages[tag] != nil {
+			return tag, true
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return "", false
+}
+
+// message returns the message of key in the language of ctx
+func message(ctx context.Context, key string) string {
+	lang, ok := ctx.Value(languageKey{}).(string)
+	if !ok {
+		lang = defaultLanguage
+	}
+	return messages[lang][key]
+}
+
+// localizingMiddleware adds the verdict message in the language of the
+// request to responses of validate, filter, check and detect violating the
+// dictionary or escalated by offendersMiddleware
+func localizingMiddleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, hits := withHits(ctx)
+			response, err := next(ctx, request)
+			if blocked, ok := err.(blockedError); ok {
+				blocked.message = verdictMessage(ctx, blocked.verdict, true)
+				return nil, blocked
+			}
+			if err != nil {
+				return response, err
+			}
+
+			violated := violation(*hits, response)
+			switch resp := response.(type) {
+			case validateResponse:
+				resp.Message = verdictMessage(ctx, resp.Verdict, violated)
+				return resp, nil
+			case filterResponse:
+				resp.Message = verdictMessage(ctx, resp.Verdict, violated)
+				return resp, nil
+			case checkResponse:
+				resp.Message = verdictMessage(ctx, resp.Verdict, violated)
+				return resp, nil
+			case detectResponse:
+				resp.Message = verdictMessage(ctx, resp.Verdict, violated)
+				return resp, nil
+			case sentencesResponse:
+				resp.Message = verdictMessage(ctx, resp.Verdict, violated)
+				return resp, nil
+			}
+			return response, nil
+		}
+	}
+}
+
+// verdictMessage returns the message of an escalated verdict, or the one of
+// blocked words if the text violated the dictionary, empty otherwise. Users
+// are only warned about texts violating it, while muted or blocked users are
+// told whatever they send.
+func verdictMessage(ctx context.Context, verdict string, violated bool) string {
+	if verdict == offenders.Mute || verdict == offenders.Block || (verdict == offenders.Warn && violated) {
+		if m := message(ctx, verdict); len(m) > 0 {
+			return m
+		}
+	}
+	if violated {
+		return message(ctx, blockedMessage)
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/log"
+)
+
+// requestLogger logs the requests of loggingTextServiceMiddleware according
+// to the method they are made with: the text of requests is logged only for
+// methods in text, and only 1 in every requests of a method is logged
+type requestLogger struct {
+	next  log.Logger
+	text  map[string]bool
+	every map[string]uint64 // 0 disables logging, 1 if missing
+	count map[string]*uint64
+}
+
+// textKeys hold the text of requests and responses
+var textKeys = map[string]bool{"text": true, "filtered": true}
+
+func newRequestLogger(next log.Logger, text []string, every map[string]uint64) log.Logger {
+	l := requestLogger{
+		next:  next,
+		text:  make(map[string]bool),
+		every: every,
+		count: make(map[string]*uint64),
+	}
+	for _, method := range text {
+		l.text[strings.TrimSpace(method)] = true
+	}
+	for method := range every {
+		l.count[method] = new(uint64)
+	}
+	return l
+}
+
+func (l requestLogger) Log(keyvals ...interface{}) error {
+	var method string
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if keyvals[i] == "method" {
+			method = fmt.Sprint(keyvals[i+1])
+			break
+		}
+	}
+
+	if every, ok := l.every[method]; ok {
+		if every == 0 || (atomic.AddUint64(l.count[method], 1)-1)%every != 0 {
+			return nil
+		}
+	}
+	if l.text[method] {
+		return l.next.Log(keyvals...)
+	}
+
+	kept := make([]interface{}, 0, len(keyvals))
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		if key, ok := keyvals[i].(string); ok && textKeys[key] {
+			continue
+		}
+		kept = append(kept, keyvals[i], keyvals[i+1])
+	}
+	return l.next.Log(kept...)
+}
+
+// parseSampling parses "filter=100,validate=0" into the number of requests
+// per logged one by method
+func parseSampling(s string) (map[string]uint64, error) {
+	every := make(map[string]uint64)
+	for _, field := range strings.Split(s, ",") {
+		if field = strings.TrimSpace(field); len(field) == 0 {
+			continue
+		}
+		i := strings.Index(field, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid sampling %q, expected method=n", field)
+		}
+		n, err := strconv.ParseUint(field[i+1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sampling %q, expected method=n", field)
+		}
+		every[strings.TrimSpace(field[:i])] = n
+	}
+	return every, nil
+}
@@ -5,69 +5,432 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
-	"os/signal"
+	"os"
 	"runtime"
-
+	"strings"
 	"time"
 
-	"io"
-	"os"
-
-	"syscall"
-
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
-	httptransport "github.com/go-kit/kit/transport/http"
-	"github.com/gorilla/mux"
-	"github.com/natefinch/lumberjack"
 	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/stats"
+	"github.com/natefinch/lumberjack"
 )
 
 type TextService interface {
-	Validate(text string) bool
-	Filter(text string) string
+	Validate(ctx context.Context, text string) (bool, error)
+	Filter(ctx context.Context, text string) (string, error)
+	Check(ctx context.Context, text string) (bool, string, error)
+	Detect(ctx context.Context, text string) ([]dict.Hit, error)
+	Sentences(ctx context.Context, text string) ([]dict.Sentence, error)
+}
+
+type textService struct {
+	strict bool
+	block  map[string]bool // categories rejected in strict mode, all if empty
 }
 
-type textService struct{}
+// blockedError is returned by Filter in strict mode when text contains words
+// of blocked categories, it's encoded as 422 with the words as reasons
+type blockedError struct {
+	reasons []dict.Hit
+	verdict string
+	message string
+}
 
-func (textService) Validate(text string) bool {
-	return dict.ExistInvalidWord(text) == false
+func (e blockedError) Error() string {
+	return "message contains blocked words"
 }
 
-func (textService) Filter(text string) string {
-	return dict.ReplaceInvalidWords(text)
+func (e blockedError) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+func (e blockedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Error   string     `json:"error"`
+		Reasons []dict.Hit `json:"reasons"`
+		Verdict string     `json:"verdict,omitempty"`
+		Message string     `json:"message,omitempty"`
+	}{e.Error(), e.reasons, e.verdict, e.message})
+}
+
+func (textService) Validate(ctx context.Context, text string) (bool, error) {
+	hits, err := dict.DetectContext(ctx, text)
+	recordHits(ctx, hits)
+	return len(hits) == 0, err
+}
+
+func (s textService) Filter(ctx context.Context, text string) (string, error) {
+	if s.strict {
+		_, filtered, err := s.Check(ctx, text)
+		return filtered, err
+	}
+	filtered, hits, err := dict.FilterContext(ctx, text)
+	recordHits(ctx, hits)
+	return filtered, err
+}
+
+// Check is Validate and Filter in a single pass over text
+func (s textService) Check(ctx context.Context, text string) (bool, string, error) {
+	filtered, hits, err := dict.FilterContext(ctx, text)
+	if err != nil {
+		return false, "", err
+	}
+	recordHits(ctx, hits)
+	if s.strict {
+		if reasons, _ := s.blocking(hits); len(reasons) > 0 {
+			return false, "", blockedError{reasons: reasons}
+		}
+	}
+	return len(hits) == 0, filtered, nil
+}
+
+// blocking splits hits into the ones of blocked categories and the others,
+// which are only masked in strict mode
+func (s textService) blocking(hits []dict.Hit) (blocked, masked []dict.Hit) {
+	for _, hit := range hits {
+		if len(s.block) == 0 || s.block[hit.Category] {
+			blocked = append(blocked, hit)
+		} else {
+			masked = append(masked, hit)
+		}
+	}
+	return
+}
+
+func (textService) Detect(ctx context.Context, text string) ([]dict.Hit, error) {
+	hits, err := dict.DetectContext(ctx, text)
+	recordHits(ctx, hits)
+	return hits, err
+}
+
+func (textService) Sentences(ctx context.Context, text string) ([]dict.Sentence, error) {
+	return dict.SentencesContext(ctx, text)
 }
 
 type validateRequest struct {
-	S string `json:"message"`
+	S    string `json:"message"`
+	User string `json:"user"`
 }
 
 type validateResponse struct {
-	V bool `json:"result"`
+	V       bool     `json:"result"`
+	Score   *float64 `json:"score,omitempty"`
+	Verdict string   `json:"verdict,omitempty"`
+	Message string   `json:"message,omitempty"`
 }
 
 type filterRequest struct {
-	S string `json:"message"`
+	S    string `json:"message"`
+	User string `json:"user"`
 }
 
 type filterResponse struct {
-	V string `json:"result"`
+	V       string   `json:"result"`
+	Score   *float64 `json:"score,omitempty"`
+	Verdict string   `json:"verdict,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+type checkRequest struct {
+	S    string `json:"message"`
+	User string `json:"user"`
+}
+
+type checkResponse struct {
+	Valid    bool     `json:"valid"`
+	Filtered string   `json:"filtered"`
+	Score    *float64 `json:"score,omitempty"`
+	Verdict  string   `json:"verdict,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+type detectRequest struct {
+	S    string `json:"message"`
+	Mode string `json:"mode"`
+	User string `json:"user"`
+}
+
+type detectResponse struct {
+	V       []dict.Hit `json:"result"`
+	Score   *float64   `json:"score,omitempty"`
+	Verdict string     `json:"verdict,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+type sentencesResponse struct {
+	V       []dict.Sentence `json:"result"`
+	Verdict string          `json:"verdict,omitempty"`
+	Message string          `json:"message,omitempty"`
 }
 
 func makeValidateEndpoint(svc TextService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(validateRequest)
-		v := svc.Validate(req.S)
-		return validateResponse{v}, nil
+		v, err := svc.Validate(ctx, req.S)
+		if err != nil {
+			return nil, err
+		}
+		return validateResponse{V: v}, nil
 	}
 }
 
 func makeFilterEndpoint(svc TextService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(filterRequest)
-		v := svc.Filter(req.S)
-		return filterResponse{v}, nil
+		v, err := svc.Filter(ctx, req.S)
+		if err != nil {
+			return nil, err
+		}
+		return filterResponse{V: v}, nil
+	}
+}
+
+func makeCheckEndpoint(svc TextService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(checkRequest)
+		valid, filtered, err := svc.Check(ctx, req.S)
+		if err != nil {
+			return nil, err
+		}
+		return checkResponse{Valid: valid, Filtered: filtered}, nil
+	}
+}
+
+func makeDetectEndpoint(svc TextService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(detectRequest)
+		if req.Mode == "sentence" {
+			v, err := svc.Sentences(ctx, req.S)
+			if err != nil {
+				return nil, err
+			}
+			return sentencesResponse{V: v}, nil
+		}
+		v, err := svc.Detect(ctx, req.S)
+		if err != nil {
+			return nil, err
+		}
+		return detectResponse{V: v}, nil
+	}
+}
+
+type wordRequest struct {
+	Word    string
+	Enabled bool
+}
+
+type wordResponse struct {
+	Word    string `json:"word"`
+	Enabled bool   `json:"enabled"`
+}
+
+func makeWordEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(wordRequest)
+		if req.Enabled {
+			return wordResponse{dict.Enable(req.Word), true}, nil
+		}
+		return wordResponse{dict.Disable(req.Word), false}, nil
+	}
+}
+
+type disabledResponse struct {
+	V []string `json:"result"`
+}
+
+func makeDisabledEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return disabledResponse{dict.Disabled()}, nil
+	}
+}
+
+type sourceRequest struct {
+	Source  string
+	Enabled bool
+}
+
+type sourceResponse struct {
+	Source  string `json:"source"`
+	Enabled bool   `json:"enabled"`
+}
+
+func makeSourceEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(sourceRequest)
+		if req.Enabled {
+			dict.EnableSource(req.Source)
+		} else {
+			dict.DisableSource(req.Source)
+		}
+		return sourceResponse{req.Source, req.Enabled}, nil
+	}
+}
+
+type dictResponse struct {
+	Revision       string             `json:"revision"`
+	CanaryRevision string             `json:"canary_revision,omitempty"`
+	Refresh        string             `json:"refresh,omitempty"` // interval between reloads, see -dict.refresh
+	Files          []dict.SourceCount `json:"files"`
+}
+
+// makeDictEndpoint describes the stable dictionary with the number of words
+// of every file it's loaded from
+func makeDictEndpoint(refresh time.Duration) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		resp := dictResponse{Files: dict.Sources()}
+		resp.Revision, resp.CanaryRevision = dict.Revisions(ctx)
+		if refresh > 0 {
+			resp.Refresh = refresh.String()
+		}
+		return resp, nil
+	}
+}
+
+// makeEntriesEndpoint returns the entries of the stable dictionary, in the
+// format /admin/dict/push takes
+func makeEntriesEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return dict.Entries(), nil
+	}
+}
+
+type testRequest struct {
+	S     string
+	Words []string
+}
+
+type testResponse struct {
+	V    []dict.Hit `json:"result"`
+	Live []dict.Hit `json:"live"`
+}
+
+// makeTestEndpoint matches text against a draft dictionary of candidate
+// words, the hits of the dictionary in use are returned for comparison. The
+// draft is normalized, runs the detectors and resolves overlaps like the
+// dictionary in use, but words or files disabled there and -dict.min-length
+// don't hide its hits.
+func makeTestEndpoint(normalizers []string, overlap string, detectors []string) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(testRequest)
+		draft, err := dict.New(
+			dict.WithWords(req.Words...),
+			dict.WithNormalizers(normalizers...),
+			dict.WithOverlap(overlap),
+			dict.WithDetectors(detectors...),
+		)
+		if err != nil {
+			return nil, err
+		}
+		hits, err := draft.Detect(ctx, req.S)
+		if err != nil {
+			return nil, err
+		}
+		live, err := dict.DetectContext(ctx, req.S)
+		if err != nil {
+			return nil, err
+		}
+		return testResponse{hits, live}, nil
+	}
+}
+
+type explainRequest struct {
+	S string
+}
+
+type explainResponse struct {
+	dict.Explanation
+	Unblocked []dict.Hit `json:"unblocked,omitempty"` // hits masked but not rejecting the message in strict mode
+}
+
+// makeExplainEndpoint tells curators why a text passed or not, see
+// dict.Explain
+func makeExplainEndpoint(s textService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(explainRequest)
+		e, err := dict.ExplainContext(ctx, req.S)
+		if err != nil {
+			return nil, err
+		}
+		resp := explainResponse{Explanation: e}
+		if s.strict {
+			_, resp.Unblocked = s.blocking(e.Hits)
+		}
+		return resp, nil
+	}
+}
+
+type diffRequest struct {
+	From, To string
+}
+
+func makeDiffEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(diffRequest)
+		return dict.Diff(req.From, req.To)
+	}
+}
+
+type snapshotsResponse struct {
+	V []dict.Snapshot `json:"result"`
+}
+
+func makeSnapshotsEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return snapshotsResponse{dict.Snapshots()}, nil
+	}
+}
+
+type healthResponse struct {
+	Status       string           `json:"status"`
+	Dictionaries []dict.Footprint `json:"dictionaries"`
+	HeapBytes    uint64           `json:"heap_bytes"`
+	Panics       int64            `json:"panics"`
+}
+
+func makeHealthEndpoint(collector *stats.Collector) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		status := "ok"
+		if !dict.Ready() {
+			status = "loading"
+		}
+		return healthResponse{status, dict.Footprints(), m.HeapAlloc, collector.Panics()}, nil
+	}
+}
+
+func makeReadyEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		if !dict.Ready() {
+			return nil, notReadyError{}
+		}
+		return map[string]string{"status": "ok"}, nil
+	}
+}
+
+type statsRequest struct {
+	N int
+}
+
+func makeStatsEndpoint(collector *stats.Collector) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(statsRequest)
+		return collector.Snapshot(req.N), nil
+	}
+}
+
+type unusedRequest struct {
+	Window time.Duration
+}
+
+func makeUnusedEndpoint(collector *stats.Collector) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(unusedRequest)
+		return collector.Unused(dict.Words(), req.Window), nil
 	}
 }
 
@@ -80,7 +443,9 @@ func loggingMiddleware(logger log.Logger) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (interface{}, error) {
 			logger.Log("msg", "calling endpoint")
-			defer logger.Log("msg", "called endpoint", "took", time.Since(time.Now()))
+			defer func(begin time.Time) {
+				logger.Log("msg", "called endpoint", "took", time.Since(begin))
+			}(time.Now())
 			return next(ctx, request)
 		}
 	}
@@ -91,96 +456,143 @@ type loggingTextServiceMiddleware struct {
 	next   TextService
 }
 
-func (mw loggingTextServiceMiddleware) Validate(text string) bool {
+func (mw loggingTextServiceMiddleware) Validate(ctx context.Context, text string) (valid bool, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "validate",
+			"client", clientIPFrom(ctx),
 			"text", text,
+			"valid", valid,
+			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
-	return mw.next.Validate(text)
+
+	valid, err = mw.next.Validate(ctx, text)
+	return
 }
 
-func (mw loggingTextServiceMiddleware) Filter(text string) (filtered string) {
+func (mw loggingTextServiceMiddleware) Filter(ctx context.Context, text string) (filtered string, err error) {
 	defer func(begin time.Time) {
 		mw.logger.Log(
 			"method", "filter",
+			"client", clientIPFrom(ctx),
+			"text", text,
+			"filtered", filtered,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	filtered, err = mw.next.Filter(ctx, text)
+	return
+}
+
+func (mw loggingTextServiceMiddleware) Check(ctx context.Context, text string) (valid bool, filtered string, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "check",
+			"client", clientIPFrom(ctx),
 			"text", text,
+			"valid", valid,
 			"filtered", filtered,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	valid, filtered, err = mw.next.Check(ctx, text)
+	return
+}
+
+func (mw loggingTextServiceMiddleware) Detect(ctx context.Context, text string) (hits []dict.Hit, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "detect",
+			"client", clientIPFrom(ctx),
+			"text", text,
+			"hits", len(hits),
+			"rules", rules(hits),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	hits, err = mw.next.Detect(ctx, text)
+	return
+}
+
+// rules joins the rule ids of hits for logs
+func rules(hits []dict.Hit) string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.Rule
+	}
+	return strings.Join(ids, ",")
+}
+
+func (mw loggingTextServiceMiddleware) Sentences(ctx context.Context, text string) (sentences []dict.Sentence, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "sentences",
+			"client", clientIPFrom(ctx),
+			"text", text,
+			"sentences", len(sentences),
+			"err", err,
 			"took", time.Since(begin),
 		)
 	}(time.Now())
 
-	filtered = mw.next.Filter(text)
+	sentences, err = mw.next.Sentences(ctx, text)
 	return
 }
 
 func main() {
-	var (
-		httpAddr = flag.String("http.addr", ":8000", "Address for HTTP server")
-		dictPath = flag.String("dict.path", "*.txt", "Files to load as dictionary, glob pattern is supported")
-		logDir   = flag.String("log.dir", "", "Log directory")
-	)
+	if len(os.Args) > 1 && os.Args[1] == "sync" {
+		os.Exit(runSync(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplay(os.Args[2:]))
+	}
+
+	c := newConfig(flag.CommandLine)
 	flag.Parse()
 
 	runtime.GOMAXPROCS(runtime.NumCPU())
-	dict.Load(*dictPath)
 
 	var w io.Writer
-	if len(*logDir) > 0 {
-		w = &lumberjack.Logger{Dir: *logDir, LocalTime: true}
+	if len(c.logDir) > 0 {
+		w = &lumberjack.Logger{Dir: c.logDir, LocalTime: true}
 	} else {
 		w = os.Stderr
 	}
 
+	levels, err := newLevelLogger(log.NewLogfmtLogger(w), c.logLevel)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	var logger log.Logger
-	logger = log.NewLogfmtLogger(w)
-
-	var svc TextService
-	svc = textService{}
-	svc = loggingTextServiceMiddleware{logger, svc}
-
-	var validate endpoint.Endpoint
-	validate = makeValidateEndpoint(svc)
-	validateHandler := httptransport.NewServer(
-		validate,
-		func(_ context.Context, r *http.Request) (interface{}, error) {
-			message := r.FormValue("message")
-			return validateRequest{message}, nil
-		},
-		encodeResponse,
-		httptransport.ServerAfter(),
-	)
-
-	var filter endpoint.Endpoint
-	filter = makeFilterEndpoint(svc)
-	filterHandler := httptransport.NewServer(
-		filter,
-		func(_ context.Context, r *http.Request) (interface{}, error) {
-			message := r.FormValue("message")
-			return filterRequest{message}, nil
-		},
-		encodeResponse,
-	)
-
-	r := mux.NewRouter()
-	r.Handle("/validate", validateHandler).Methods("POST")
-	r.Handle("/filter", filterHandler).Methods("POST")
-
-	// Interrupt handler.
-	errc := make(chan error)
-	go func() {
-		c := make(chan os.Signal)
-		signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-		errc <- fmt.Errorf("%s", <-c)
-	}()
-
-	// HTTP transport.
-	go func() {
-		logger.Log("transport", "HTTP", "addr", *httpAddr)
-		errc <- http.ListenAndServe(*httpAddr, r)
-	}()
-
-	logger.Log("msg", "exit", "err", <-errc)
+	logger = levels
+
+	if err := configureDict(c); err != nil {
+		logger.Log("component", "dict", "err", err)
+		os.Exit(1)
+	}
+	collector := stats.New()
+	s, err := newServer(c, levels, collector)
+	if err != nil {
+		logger.Log("component", "http", "err", err)
+		os.Exit(1)
+	}
+
+	// Components are stopped in the reverse order of their registration on
+	// shutdown: listeners are drained first, statistics flushed last.
+	lc := newLifecycle(logger, c.shutdownTimeout)
+	flushStats(lc, c, collector, logger)
+	loadDictionaries(lc, c, logger)
+	serveQueues(lc, c, s, logger, recoverer{logger, collector})
+	serveHTTP(lc, c, s, logger)
+
+	logger.Log("msg", "exit", "err", lc.Run())
 }
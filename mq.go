@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+)
+
+// mqSubscriber serves an endpoint over the request-reply of a message queue,
+// requests and responses are json like the bodies of the http api
+type mqSubscriber struct {
+	name string // of the method, prefixed to name the subject or queue
+	e    endpoint.Endpoint
+	dec  func([]byte) (interface{}, error)
+}
+
+// decodeMQRequest returns a decoder of json into values of the type of v
+func decodeMQRequest(v interface{}) func([]byte) (interface{}, error) {
+	return func(data []byte) (interface{}, error) {
+		switch v.(type) {
+		case validateRequest:
+			var req validateRequest
+			err := json.Unmarshal(data, &req)
+			return req, err
+		case filterRequest:
+			var req filterRequest
+			err := json.Unmarshal(data, &req)
+			return req, err
+		case checkRequest:
+			var req checkRequest
+			err := json.Unmarshal(data, &req)
+			return req, err
+		case detectRequest:
+			var req detectRequest
+			if err := json.Unmarshal(data, &req); err != nil {
+				return nil, err
+			}
+			if len(req.Mode) > 0 && req.Mode != "sentence" {
+				return nil, fmt.Errorf("unknown mode %q", req.Mode)
+			}
+			return req, nil
+		case batchRequest:
+			var req batchRequest
+			err := json.Unmarshal(data, &req)
+			return req, err
+		}
+		return nil, fmt.Errorf("unsupported request %T", v)
+	}
+}
+
+// mqContext returns the context of a request carrying header, which sets
+// its tenant, language and client like the headers of http requests do. The
+// client is given by X-Real-IP, producers of requests being trusted.
+func mqContext(header http.Header, tenants map[string]dict.Replacement) context.Context {
+	ctx := context.Background()
+	if repl, ok := tenants[header.Get("X-Tenant")]; ok {
+		ctx = dict.ContextWithReplacement(ctx, repl)
+	}
+	ctx = context.WithValue(ctx, languageKey{}, language(&http.Request{URL: &url.URL{}, Header: header}))
+	if ip := strings.TrimSpace(header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		ctx = context.WithValue(ctx, clientIPKey{}, ip)
+	}
+	return ctx
+}
+
+// respond returns the response of the endpoint of s to payload, errors are
+// encoded like the http transport does
+func (s mqSubscriber) respond(ctx context.Context, payload []byte, rec recoverer) (data []byte) {
+	defer func() {
+		if p := recover(); p != nil {
+			rec.recovered(p)
+			data = []byte(`{"error":"internal server error"}`)
+		}
+	}()
+
+	var response interface{}
+	request, err := s.dec(payload)
+	if err == nil {
+		response, err = s.e(ctx, request)
+	}
+	if err != nil {
+		if _, ok := err.(json.Marshaler); ok {
+			response = err
+		} else {
+			response = map[string]string{"error": err.Error()}
+		}
+	}
+
+	data, err = json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return data
+}
+
+// serveMQ runs a connection to the message queue server of transport until
+// stop is closed, reconnecting with a backoff when it fails. NATS connections
+// reconnect by themselves.
+func serveMQ(transport string, run func(stop <-chan struct{}) error, logger log.Logger, stop <-chan struct{}) {
+	backoff := time.Second
+	for {
+		err := run(stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		logger.Log("transport", transport, "err", err, "retry", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-stop:
+			return
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// tlsConfig returns the tls configuration of connections to host, trusting
+// the certificate authorities of the PEM file ca or the system ones if empty
+func tlsConfig(host, ca string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: host}
+	if len(ca) == 0 {
+		return config, nil
+	}
+	pem, err := ioutil.ReadFile(ca)
+	if err != nil {
+		return nil, err
+	}
+	config.RootCAs = x509.NewCertPool()
+	if !config.RootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in %s", ca)
+	}
+	return config, nil
+}
+
+// serveQueues serves the endpoints of e over the message queues configured
+// by c, the subject or queue of a method is its name prefixed as configured
+func serveQueues(lc *lifecycle, c *config, s *server, logger log.Logger, rec recoverer) {
+	e := s.endpoints
+	subscribers := []mqSubscriber{
+		{"validate", e.validate, decodeMQRequest(validateRequest{})},
+		{"filter", e.filter, decodeMQRequest(filterRequest{})},
+		{"check", e.check, decodeMQRequest(checkRequest{})},
+		{"detect", e.detect, decodeMQRequest(detectRequest{})},
+		{"validate.batch", e.validateBatch, decodeMQRequest(batchRequest{})},
+		{"filter.batch", e.filterBatch, decodeMQRequest(batchRequest{})},
+		{"detect.batch", e.detectBatch, decodeMQRequest(batchRequest{})},
+	}
+	if len(c.natsURL) > 0 {
+		go serveNATS(c.natsURL, c.natsSubject, c.natsQueue, c.natsCA, c.natsCreds, subscribers, s.tenants, logger, rec, lc.Stop("nats"))
+	}
+	if len(c.amqpURL) > 0 {
+		go serveAMQP(c.amqpURL, c.amqpQueue, c.amqpCA, subscribers, s.tenants, logger, rec, lc.Stop("amqp"))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/stats"
+)
+
+func TestMQRespond(t *testing.T) {
+	loadTestDict(t, "bad")
+	tenants := map[string]dict.Replacement{"acme": {Mask: '#'}}
+	s := mqSubscriber{"filter", func(ctx context.Context, request interface{}) (interface{}, error) {
+		filtered, _, err := dict.FilterContext(ctx, request.(filterRequest).S)
+		return map[string]string{
+			"result":  filtered,
+			"message": message(ctx, blockedMessage),
+			"client":  clientIPFrom(ctx),
+		}, err
+	}, decodeMQRequest(filterRequest{})}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   map[string]string
+	}{
+		{"defaults", http.Header{}, map[string]string{"result": "***", "message": "内容包含敏感词", "client": ""}},
+		{"headers", http.Header{
+			"X-Tenant":        {"acme"},
+			"Accept-Language": {"en-US,en;q=0.8"},
+			"X-Real-Ip":       {"203.0.113.7"},
+		}, map[string]string{"result": "###", "message": "Message contains prohibited content", "client": "203.0.113.7"}},
+		{"unknown tenant and invalid ip", http.Header{
+			"X-Tenant":  {"other"},
+			"X-Real-Ip": {"somewhere"},
+		}, map[string]string{"result": "***", "message": "内容包含敏感词", "client": ""}},
+	}
+	for _, tt := range tests {
+		data := s.respond(mqContext(tt.header, tenants), []byte(`{"message": "bad"}`), recoverer{log.NewNopLogger(), stats.New()})
+		var got map[string]string
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v: %s", tt.name, err, data)
+		}
+		for key, want := range tt.want {
+			if got[key] != want {
+				t.Errorf("%s: %s = %q, want %q", tt.name, key, got[key], want)
+			}
+		}
+	}
+}
+
+func TestMQRespondErrors(t *testing.T) {
+	s := mqSubscriber{"detect", func(ctx context.Context, request interface{}) (interface{}, error) {
+		panic("endpoint failed")
+	}, decodeMQRequest(detectRequest{})}
+	tests := []struct {
+		payload string
+		want    string
+	}{
+		{`{"message": "bad", "mode": "words"}`, `{"error":"unknown mode \"words\""}`},
+		{`{`, `{"error":"unexpected end of JSON input"}`},
+		{`{"message": "bad"}`, `{"error":"internal server error"}`},
+	}
+	for _, tt := range tests {
+		if got := string(s.respond(context.Background(), []byte(tt.payload), recoverer{log.NewNopLogger(), stats.New()})); got != tt.want {
+			t.Errorf("respond(%s) = %s, want %s", tt.payload, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	"github.com/nats-io/nats.go"
+)
+
+// serveNATS subscribes to the subjects of subscribers, prefixed with
+// subject, in queue group queue on the servers at rawurl until stop is
+// closed, the connection reconnects by itself. Connections use tls when the
+// url scheme is tls or the server requires it, trusting the authorities of
+// the PEM file ca or the system ones, and authenticate with the user of the
+// url or the credentials file creds.
+func serveNATS(rawurl, subject, queue, ca, creds string, subscribers []mqSubscriber, tenants map[string]dict.Replacement, logger log.Logger, rec recoverer, stop <-chan struct{}) {
+	options := []nats.Option{
+		nats.Name("wego"),
+		nats.MaxReconnects(-1),
+		nats.RetryOnFailedConnect(true),
+		nats.ReconnectWait(time.Second),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			logger.Log("transport", "NATS", "addr", c.ConnectedAddr(), "tls", c.TLSRequired())
+		}),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logger.Log("transport", "NATS", "err", err)
+			}
+		}),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			logger.Log("transport", "NATS", "err", err)
+		}),
+	}
+	if len(ca) > 0 {
+		options = append(options, nats.RootCAs(ca))
+	}
+	if len(creds) > 0 {
+		options = append(options, nats.UserCredentials(creds))
+	}
+	conn, err := nats.Connect(rawurl, options...)
+	if err != nil {
+		logger.Log("transport", "NATS", "err", err)
+		return
+	}
+	for _, s := range subscribers {
+		s := s
+		_, err := conn.QueueSubscribe(subject+"."+s.name, queue, func(m *nats.Msg) {
+			if len(m.Reply) == 0 {
+				return // not a request
+			}
+			// header names are kept as sent by NATS
+			header := make(http.Header)
+			for key, values := range m.Header {
+				for _, value := range values {
+					header.Add(key, value)
+				}
+			}
+			go func() {
+				m.Respond(s.respond(mqContext(header, tenants), m.Data, rec))
+			}()
+		})
+		if err != nil {
+			logger.Log("transport", "NATS", "err", err)
+			conn.Close()
+			return
+		}
+	}
+
+	if conn.IsConnected() {
+		logger.Log("transport", "NATS", "addr", conn.ConnectedAddr(), "tls", conn.TLSRequired())
+	}
+
+	<-stop
+	conn.Drain()
+}
@@ -0,0 +1,112 @@
+// Package offenders counts recent violations of users to escalate verdicts
+// on repeated offenders
+package offenders
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Tracker counts the violations of users in a sliding window
+type Tracker interface {
+	// Add records a violation of user and returns the number of violations
+	// in the window including it
+	Add(ctx context.Context, user string) (int, error)
+	// Count returns the number of violations of user in the window
+	Count(ctx context.Context, user string) (int, error)
+}
+
+// Memory is a Tracker keeping violations in memory, they are lost on restart
+// and not shared between instances
+type Memory struct {
+	mu         sync.Mutex
+	window     time.Duration
+	violations map[string][]time.Time
+	swept      time.Time
+}
+
+// NewMemory returns a Tracker counting violations in window
+func NewMemory(window time.Duration) *Memory {
+	return &Memory{
+		window:     window,
+		violations: make(map[string][]time.Time),
+		swept:      time.Now(),
+	}
+}
+
+// Add implements Tracker
+func (m *Memory) Add(_ context.Context, user string) (int, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+	m.violations[user] = append(m.recent(user, now), now)
+	return len(m.violations[user]), nil
+}
+
+// Count implements Tracker
+func (m *Memory) Count(_ context.Context, user string) (int, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.recent(user, now)), nil
+}
+
+// recent drops the violations of user out of the window
+func (m *Memory) recent(user string, now time.Time) []time.Time {
+	times := m.violations[user]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= m.window {
+		i++
+	}
+	if i == len(times) {
+		delete(m.violations, user)
+		return nil
+	}
+	times = times[i:]
+	m.violations[user] = times
+	return times
+}
+
+// sweep forgets users without violations in the window once per window
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.swept) < m.window {
+		return
+	}
+	for user := range m.violations {
+		m.recent(user, now)
+	}
+	m.swept = now
+}
+
+// Escalation maps numbers of violations to verdicts, a threshold of 0 skips
+// its verdict
+type Escalation struct {
+	Warn  int
+	Mute  int
+	Block int
+}
+
+// Verdicts of Escalation, from the mildest
+const (
+	OK    = "ok"
+	Warn  = "warn"
+	Mute  = "mute"
+	Block = "block"
+)
+
+// Verdict returns the verdict for n violations
+func (e Escalation) Verdict(n int) string {
+	switch {
+	case e.Block > 0 && n >= e.Block:
+		return Block
+	case e.Mute > 0 && n >= e.Mute:
+		return Mute
+	case e.Warn > 0 && n >= e.Warn:
+		return Warn
+	}
+	return OK
+}
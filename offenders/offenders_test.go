@@ -0,0 +1,71 @@
+package offenders
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(50 * time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		if n, err := m.Add(ctx, "alice"); err != nil || n != i {
+			t.Fatalf("Add(alice) = %d, %v, want %d", n, err, i)
+		}
+	}
+	if n, _ := m.Count(ctx, "alice"); n != 3 {
+		t.Errorf("Count(alice) = %d, want 3", n)
+	}
+	if n, _ := m.Count(ctx, "bob"); n != 0 {
+		t.Errorf("Count(bob) = %d, want 0", n)
+	}
+
+	// violations out of the window are no longer counted
+	time.Sleep(60 * time.Millisecond)
+	if n, _ := m.Count(ctx, "alice"); n != 0 {
+		t.Errorf("Count(alice) after the window = %d, want 0", n)
+	}
+	if n, _ := m.Add(ctx, "alice"); n != 1 {
+		t.Errorf("Add(alice) after the window = %d, want 1", n)
+	}
+
+	// users without violations in the window are forgotten
+	m.Add(ctx, "bob")
+	time.Sleep(60 * time.Millisecond)
+	m.Add(ctx, "carol")
+	m.mu.Lock()
+	_, alice := m.violations["alice"]
+	_, bob := m.violations["bob"]
+	m.mu.Unlock()
+	if alice || bob {
+		t.Errorf("expired users kept: alice %v, bob %v", alice, bob)
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	tests := []struct {
+		e    Escalation
+		n    int
+		want string
+	}{
+		{Escalation{1, 3, 5}, 0, OK},
+		{Escalation{1, 3, 5}, 1, Warn},
+		{Escalation{1, 3, 5}, 2, Warn},
+		{Escalation{1, 3, 5}, 3, Mute},
+		{Escalation{1, 3, 5}, 5, Block},
+		{Escalation{1, 3, 5}, 9, Block},
+		{Escalation{2, 0, 4}, 3, Warn}, // mute skipped
+		{Escalation{2, 0, 4}, 4, Block},
+		{Escalation{0, 0, 2}, 1, OK}, // warn and mute skipped
+		{Escalation{0, 0, 2}, 2, Block},
+		{Escalation{1, 2, 0}, 10, Mute}, // never blocked
+		{Escalation{}, 100, OK},
+	}
+	for _, tt := range tests {
+		if got := tt.e.Verdict(tt.n); got != tt.want {
+			t.Errorf("%+v.Verdict(%d) = %q, want %q", tt.e, tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/offenders"
+)
+
+// offendersMiddleware counts the violations of the user given with validate,
+// filter, check and detect requests and adds the escalated verdict to
+// responses.
+// Requests without user are left alone, and tracker failures are logged
+// without verdict.
+func offendersMiddleware(tracker offenders.Tracker, escalation offenders.Escalation, logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			var user string
+			switch req := request.(type) {
+			case validateRequest:
+				user = req.User
+			case filterRequest:
+				user = req.User
+			case checkRequest:
+				user = req.User
+			case detectRequest:
+				user = req.User
+			}
+			if len(user) == 0 {
+				return next(ctx, request)
+			}
+			ctx, hits := withHits(ctx)
+			response, err := next(ctx, request)
+
+			blocked, isBlocked := err.(blockedError)
+			if err != nil && !isBlocked {
+				return response, err
+			}
+
+			var n int
+			var terr error
+			if isBlocked || violation(*hits, response) {
+				n, terr = tracker.Add(ctx, user)
+			} else {
+				n, terr = tracker.Count(ctx, user)
+			}
+			if terr != nil {
+				logger.Log("component", "offenders", "user", user, "err", terr)
+				return response, err
+			}
+			verdict := escalation.Verdict(n)
+
+			if isBlocked {
+				blocked.verdict = verdict
+				return nil, blocked
+			}
+			switch resp := response.(type) {
+			case validateResponse:
+				resp.Verdict = verdict
+				return resp, nil
+			case filterResponse:
+				resp.Verdict = verdict
+				return resp, nil
+			case checkResponse:
+				resp.Verdict = verdict
+				return resp, nil
+			case detectResponse:
+				resp.Verdict = verdict
+				return resp, nil
+			case sentencesResponse:
+				resp.Verdict = verdict
+				return resp, nil
+			}
+			return response, nil
+		}
+	}
+}
+
+// violation tells if the response reports blocked words, hits are the words
+// recorded while serving it, which are dropped for messages allowed by the
+// scoring service. Filter is decided by hits as masking changes messages
+// which are not valid utf-8 too.
+func violation(hits []dict.Hit, response interface{}) bool {
+	switch resp := response.(type) {
+	case detectResponse:
+		return len(resp.V) > 0
+	case sentencesResponse:
+		for _, s := range resp.V {
+			if !s.Valid {
+				return true
+			}
+		}
+		return false
+	}
+	return len(hits) > 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/goofansu/wego/offenders"
+)
+
+func TestOffenders(t *testing.T) {
+	loadTestDict(t, "bad")
+	s := newTestServer(t, "-offenders.warn", "1", "-offenders.mute", "2", "-offenders.block", "0")
+	tests := []struct {
+		path    string
+		user    string
+		message string
+		want    string
+	}{
+		{"/filter", "alice", "\xffgood", offenders.OK},
+		{"/check", "alice", "\xffgood", offenders.OK},
+		{"/detect", "alice", "\xffgood", offenders.OK},
+		{"/validate", "alice", "\xffgood", offenders.OK},
+		{"/filter", "alice", "bad", offenders.Warn},
+		{"/check", "alice", "good", offenders.Warn},
+		{"/detect", "alice", "bad", offenders.Mute},
+		{"/validate", "bob", "bad", offenders.Warn},
+		{"/filter", "", "bad", ""},
+	}
+	for _, tt := range tests {
+		form := url.Values{"message": {tt.message}, "user": {tt.user}}
+		r := httptest.NewRequest("POST", tt.path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.api.ServeHTTP(w, r)
+		var resp struct {
+			Verdict string `json:"verdict"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Verdict != tt.want {
+			t.Errorf("%s %q by %q: verdict = %q, want %q", tt.path, tt.message, tt.user, resp.Verdict, tt.want)
+		}
+		if tt.want == offenders.OK && len(resp.Message) > 0 {
+			t.Errorf("%s %q by %q: message = %q, want none", tt.path, tt.message, tt.user, resp.Message)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goofansu/wego/dict"
+)
+
+// route is an endpoint of the http api, routes are registered and documented
+// from the same table so the OpenAPI document can't drift from the handlers
+type route struct {
+	method  string
+	path    string
+	handler http.Handler
+	doc     apiDoc
+}
+
+// apiDoc describes an operation in the OpenAPI document
+type apiDoc struct {
+	Summary string
+	Params  []apiParam
+	// Responses are values of the types an operation may respond with on
+	// success, more than one are documented as alternatives
+	Responses []interface{}
+	// Errors are the documented error statuses with their descriptions
+	Errors map[int]string
+}
+
+// apiParam is a form field of POST requests or a query parameter of GET ones
+type apiParam struct {
+	Name        string
+	Description string
+	Type        string // string, integer or array of strings
+	Required    bool
+	Enum        []string
+}
+
+// errorResponse is the body of error responses
+type errorResponse struct {
+	Error   string            `json:"error"`
+	Reasons []dict.Hit        `json:"reasons,omitempty"`
+	Partial *bool             `json:"partial,omitempty"`
+	Lines   []dict.ImportLine `json:"lines,omitempty"`
+}
+
+// openAPI returns the OpenAPI 3 document of routes
+func openAPI(routes []route) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+	for _, rt := range routes {
+		op := map[string]interface{}{
+			"summary":     rt.doc.Summary,
+			"operationId": operationID(rt.method, rt.path),
+		}
+
+		if len(rt.doc.Params) > 0 {
+			if rt.method == "GET" {
+				var params []interface{}
+				for _, p := range rt.doc.Params {
+					params = append(params, map[string]interface{}{
+						"name":        p.Name,
+						"in":          "query",
+						"description": p.Description,
+						"required":    p.Required,
+						"schema":      p.schema(),
+					})
+				}
+				op["parameters"] = params
+			} else {
+				properties := make(map[string]interface{})
+				var required []string
+				for _, p := range rt.doc.Params {
+					s := p.schema()
+					s["description"] = p.Description
+					properties[p.Name] = s
+					if p.Required {
+						required = append(required, p.Name)
+					}
+				}
+				body := map[string]interface{}{"type": "object", "properties": properties}
+				if len(required) > 0 {
+					body["required"] = required
+				}
+				op["requestBody"] = map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/x-www-form-urlencoded": map[string]interface{}{"schema": body},
+					},
+				}
+			}
+		}
+
+		var alternatives []interface{}
+		for _, v := range rt.doc.Responses {
+			alternatives = append(alternatives, schemaOf(reflect.TypeOf(v), schemas))
+		}
+		var schema interface{} = map[string]interface{}{}
+		switch len(alternatives) {
+		case 0:
+		case 1:
+			schema = alternatives[0]
+		default:
+			schema = map[string]interface{}{"oneOf": alternatives}
+		}
+		responses := map[string]interface{}{
+			"200": jsonResponse("OK", schema),
+		}
+		errorSchema := schemaOf(reflect.TypeOf(errorResponse{}), schemas)
+		for code, description := range rt.doc.Errors {
+			responses[strconv.Itoa(code)] = jsonResponse(description, errorSchema)
+		}
+		op["responses"] = responses
+
+		if paths[rt.path] == nil {
+			paths[rt.path] = make(map[string]interface{})
+		}
+		paths[rt.path][strings.ToLower(rt.method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "wego",
+			"description": "Validate, filter and detect blocked words in text",
+			"version":     "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func (p apiParam) schema() map[string]interface{} {
+	var s map[string]interface{}
+	switch p.Type {
+	case "array":
+		s = map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}}
+	case "":
+		s = map[string]interface{}{"type": "string"}
+	default:
+		s = map[string]interface{}{"type": p.Type}
+	}
+	if len(p.Enum) > 0 {
+		s["enum"] = p.Enum
+	}
+	return s
+}
+
+func jsonResponse(description string, schema interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// operationID turns "POST /admin/words/enable" into "postAdminWordsEnable"
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '.' || r == '-' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf returns the json schema of t following encoding/json rules, named
+// structs of other packages are added to schemas and referenced
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case t.Kind() != reflect.Struct:
+		return map[string]interface{}{}
+	}
+
+	// response types of this package are inlined, shared types are referenced
+	name := ""
+	if t.PkgPath() != reflect.TypeOf(route{}).PkgPath() && len(t.Name()) > 0 {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+		if _, ok := schemas[name]; ok {
+			return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		}
+		schemas[name] = map[string]interface{}{} // placeholder for recursive types
+	}
+
+	properties := make(map[string]interface{})
+	required := fieldsOf(t, properties, schemas)
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	if len(name) == 0 {
+		return schema
+	}
+	schemas[name] = schema
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// fieldsOf adds the schemas of the fields of the struct t to properties,
+// fields of embedded structs are promoted like encoding/json does, the
+// required ones are returned
+func fieldsOf(t reflect.Type, properties map[string]interface{}, schemas map[string]interface{}) []string {
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		parts := strings.Split(tag, ",")
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && len(parts[0]) == 0 {
+			required = append(required, fieldsOf(f.Type, properties, schemas)...)
+			continue
+		}
+		if len(f.PkgPath) > 0 || tag == "-" {
+			continue
+		}
+		field := parts[0]
+		if len(field) == 0 {
+			field = f.Name
+		}
+		properties[field] = schemaOf(f.Type, schemas)
+		omitempty := false
+		for _, opt := range parts[1:] {
+			omitempty = omitempty || opt == "omitempty"
+		}
+		if !omitempty {
+			required = append(required, field)
+		}
+	}
+	return required
+}
+
+// swaggerUI is a page rendering /openapi.json with Swagger UI from a CDN
+const swaggerUI = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>wego</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
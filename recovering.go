@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/stats"
+)
+
+// recoverer handles the panics recovered while serving requests
+type recoverer struct {
+	logger log.Logger
+	stats  *stats.Collector
+}
+
+// recovered counts p and logs it with the stack, which is never sent to
+// clients
+func (r recoverer) recovered(p interface{}) {
+	r.stats.Panic()
+	r.logger.Log("msg", "panic", "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+}
+
+// recoveringHandler responds 500 to requests panicking in next instead of
+// letting one bad input kill the process
+func recoveringHandler(next http.Handler, rec recoverer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			rec.recovered(p)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"internal server error"}` + "\n"))
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-logfmt/logfmt"
+	"github.com/goofansu/wego/dict"
+)
+
+// replayed is a request read from a request log
+type replayed struct {
+	method string
+	text   string
+	hit    string // "true" or "false" if the log tells, empty otherwise
+}
+
+// recordedHit tells from the keys logged with a request whether its text
+// matched the dictionary then
+func recordedHit(method string, keyvals map[string]string) string {
+	switch method {
+	case "validate", "check":
+		switch keyvals["valid"] {
+		case "true":
+			return "false"
+		case "false":
+			return "true"
+		}
+	case "filter":
+		if filtered, ok := keyvals["filtered"]; ok {
+			return fmt.Sprint(filtered != keyvals["text"])
+		}
+	case "detect":
+		if hits, ok := keyvals["hits"]; ok {
+			return fmt.Sprint(hits != "0")
+		}
+	}
+	return ""
+}
+
+// runReplay is the wego replay command, it returns the exit code
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("wego replay", flag.ExitOnError)
+	var (
+		dictPath    = fs.String("dict", "*.txt", "Dictionary to replay requests against, like -dict.path")
+		normalizers = fs.String("normalizers", "", "Comma separated normalizers like -dict.normalizers")
+		overlap     = fs.String("overlap", dict.LeftmostLongest, "Policy for overlapping words like -dict.overlap")
+		detectors   = fs.String("detectors", "", "Comma separated builtin detectors like -dict.detectors")
+		all         = fs.Bool("all", false, "Report every request replayed, not only the ones whose verdict changed")
+		fail        = fs.Bool("fail", false, "Exit with status 1 if a verdict changed, for CI")
+	)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: wego replay [flags] [request log files, stdin if none]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	logger := log.NewLogfmtLogger(os.Stdout)
+	errLogger := log.NewLogfmtLogger(os.Stderr)
+	if err := dict.SetNormalizers(dict.ParseNormalizers(*normalizers)...); err != nil {
+		errLogger.Log("err", err)
+		return 2
+	}
+	if err := dict.SetOverlap(*overlap); err != nil {
+		errLogger.Log("err", err)
+		return 2
+	}
+	if err := dict.SetDetectors(strings.FieldsFunc(*detectors, func(r rune) bool { return r == ',' || r == ' ' })...); err != nil {
+		errLogger.Log("err", err)
+		return 2
+	}
+	if err := dict.Load(*dictPath); err != nil {
+		errLogger.Log("err", err)
+		return 2
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	var replayedN, unknown, hits, clears int
+	for _, file := range files {
+		var r io.Reader = os.Stdin
+		if file != "-" {
+			f, err := os.Open(file)
+			if err != nil {
+				errLogger.Log("err", err)
+				return 2
+			}
+			defer f.Close()
+			r = f
+		}
+
+		err := readRequests(r, func(line int, req replayed) {
+			replayedN++
+			words := dict.InvalidWords(req.text)
+			now := fmt.Sprint(len(words) > 0)
+			changed := len(req.hit) > 0 && req.hit != now
+			switch {
+			case len(req.hit) == 0:
+				unknown++
+			case changed && now == "true":
+				hits++
+			case changed:
+				clears++
+			}
+			if changed || *all {
+				logger.Log(
+					"file", file,
+					"line", line,
+					"method", req.method,
+					"was", req.hit,
+					"now", now,
+					"words", strings.Join(words, ","),
+					"text", req.text,
+				)
+			}
+		})
+		if err != nil {
+			errLogger.Log("file", file, "err", err)
+			return 2
+		}
+	}
+
+	errLogger.Log("replayed", replayedN, "unknown", unknown, "newly_hit", hits, "newly_clear", clears)
+	if *fail && hits+clears > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readRequests calls replay with the requests logged with their text by
+// loggingTextServiceMiddleware in r, other lines and failed requests are
+// skipped
+func readRequests(r io.Reader, replay func(line int, req replayed)) error {
+	dec := logfmt.NewDecoder(r)
+	for line := 1; dec.ScanRecord(); line++ {
+		keyvals := make(map[string]string)
+		for dec.ScanKeyval() {
+			keyvals[string(dec.Key())] = string(dec.Value())
+		}
+		if dec.Err() != nil {
+			return fmt.Errorf("line %d: %v", line, dec.Err())
+		}
+
+		method := keyvals["method"]
+		text, ok := keyvals["text"]
+		if !ok || (keyvals["err"] != "null" && len(keyvals["err"]) > 0) {
+			continue
+		}
+		switch method {
+		case "validate", "filter", "check", "detect", "sentences":
+			replay(line, replayed{method, text, recordedHit(method, keyvals)})
+		}
+	}
+	return dec.Err()
+}
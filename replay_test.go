@@ -0,0 +1,91 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goofansu/wego/dict"
+)
+
+const replayLog = `component=lifecycle msg=started
+method=validate client=10.0.0.1 text="so bad" valid=false err=null took=12µs
+method=validate client=10.0.0.1 text=fine valid=true err=null took=12µs
+method=filter client=10.0.0.1 text="new word" filtered="new word" err=null took=12µs
+method=detect client=10.0.0.1 text="bad again" hits=1 err=null took=12µs
+method=check client=10.0.0.1 text=failed valid=false err="server is overloaded" took=12µs
+method=validate client=10.0.0.1 valid=true err=null took=12µs
+method=sentences client=10.0.0.1 text="bad. fine." err=null took=12µs
+method=explain client=10.0.0.1 text=bad err=null took=12µs
+`
+
+func TestReadRequests(t *testing.T) {
+	var got []replayed
+	var lines []int
+	err := readRequests(strings.NewReader(replayLog), func(line int, req replayed) {
+		got = append(got, req)
+		lines = append(lines, line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []replayed{
+		{"validate", "so bad", "true"},
+		{"validate", "fine", "false"},
+		{"filter", "new word", "false"},
+		{"detect", "bad again", "true"},
+		{"sentences", "bad. fine.", ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed %+v, want %+v", got, want)
+	}
+	if want := []int{2, 3, 4, 5, 8}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines %v, want %v", lines, want)
+	}
+
+	if err := readRequests(strings.NewReader("method=validate text=\"unterminated\n"), func(int, replayed) {}); err == nil {
+		t.Error("invalid logfmt replayed")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	dictPath := filepath.Join(dir, "words.txt")
+	logPath := filepath.Join(dir, "requests.log")
+	if err := ioutil.WriteFile(logPath, []byte(replayLog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		dict.SetNormalizers()
+		dict.SetOverlap(dict.LeftmostLongest)
+		dict.SetDetectors()
+	})
+
+	tests := []struct {
+		name  string
+		words string
+		flags []string
+		log   string
+		want  int
+	}{
+		{"unchanged", "bad\n", []string{"-fail"}, logPath, 0},
+		{"newly hit", "bad\nnew\n", []string{"-fail"}, logPath, 1},
+		{"newly clear", "worse\n", []string{"-fail"}, logPath, 1},
+		{"changed without fail", "new\n", nil, logPath, 0},
+		{"unknown detector", "bad\n", []string{"-detectors", "fax"}, logPath, 2},
+		{"missing log", "bad\n", nil, filepath.Join(dir, "missing.log"), 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(dictPath, []byte(tt.words), 0644); err != nil {
+				t.Fatal(err)
+			}
+			args := append(append([]string{"-dict", dictPath}, tt.flags...), tt.log)
+			if got := runReplay(args); got != tt.want {
+				t.Errorf("runReplay(%q) = %d, want %d", args, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"github.com/goofansu/wego/stats"
+)
+
+// Parameters and errors shared by the documentation of routes
+var (
+	messageParam  = apiParam{Name: "message", Description: "Text to check", Required: true}
+	messagesParam = apiParam{Name: "message", Description: "Texts to check, repeated", Type: "array", Required: true}
+	overloaded    = map[int]string{
+		http.StatusServiceUnavailable: "The dictionary is loading or too many requests are waiting, retry after the Retry-After header",
+		http.StatusGatewayTimeout:     "Matching took longer than -limit.timeout",
+	}
+	overloadedBatch = map[int]string{
+		http.StatusBadRequest:            "More messages than -limit.batch",
+		http.StatusRequestEntityTooLarge: "The body is larger than -limit.body",
+		http.StatusServiceUnavailable:    overloaded[http.StatusServiceUnavailable],
+		http.StatusGatewayTimeout:        overloaded[http.StatusGatewayTimeout],
+	}
+)
+
+// badRequestError is returned by decoders of invalid requests, it's encoded
+// as 400
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e badRequestError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e badRequestError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"error": e.Error()})
+}
+
+// requestTooLargeError is returned by decoders reading a body over the
+// limit of maxBytesHandler, it's encoded as 413
+type requestTooLargeError struct {
+	limit int64
+}
+
+func (e requestTooLargeError) Error() string {
+	return fmt.Sprintf("request body is larger than %d bytes", e.limit)
+}
+
+func (e requestTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+func (e requestTooLargeError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"error": e.Error()})
+}
+
+// bodyError returns the error of reading the body of a request as
+// requestTooLargeError if it's over its limit, as badRequestError otherwise
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return requestTooLargeError{tooLarge.Limit}
+	}
+	return badRequestError{err}
+}
+
+// maxBytesHandler limits the body of requests to next to n bytes, decoders
+// report bodies over it with bodyError. Unlimited if n <= 0.
+func maxBytesHandler(n int64, next http.Handler) http.Handler {
+	if n <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiRoutes returns the routes of the api: the endpoints of e, health checks
+// and GraphQL, whose word management goes through manage. Bodies of batch
+// requests are limited to -limit.body.
+func apiRoutes(c *config, e endpoints, collector *stats.Collector, manage endpoint.Middleware) []route {
+	validateHandler := httptransport.NewServer(
+		e.validate,
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			message := r.FormValue("message")
+			return validateRequest{message, r.FormValue("user")}, nil
+		},
+		encodeResponse,
+		httptransport.ServerAfter(),
+	)
+
+	filterHandler := httptransport.NewServer(
+		e.filter,
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			message := r.FormValue("message")
+			return filterRequest{message, r.FormValue("user")}, nil
+		},
+		encodeResponse,
+	)
+
+	checkHandler := httptransport.NewServer(
+		e.check,
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			message := r.FormValue("message")
+			return checkRequest{message, r.FormValue("user")}, nil
+		},
+		encodeResponse,
+	)
+
+	detectHandler := httptransport.NewServer(
+		e.detect,
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			message := r.FormValue("message")
+			mode := r.FormValue("mode")
+			if len(mode) > 0 && mode != "sentence" {
+				return nil, badRequestError{fmt.Errorf("unknown mode %q", mode)}
+			}
+			return detectRequest{message, mode, r.FormValue("user")}, nil
+		},
+		encodeResponse,
+	)
+
+	validateBatchHandler := maxBytesHandler(c.limitBody, httptransport.NewServer(
+		e.validateBatch,
+		decodeBatchRequest,
+		encodeResponse,
+	))
+	filterBatchHandler := maxBytesHandler(c.limitBody, httptransport.NewServer(
+		e.filterBatch,
+		decodeBatchRequest,
+		encodeResponse,
+	))
+	detectBatchHandler := maxBytesHandler(c.limitBody, httptransport.NewServer(
+		e.detectBatch,
+		decodeBatchRequest,
+		encodeResponse,
+	))
+
+	healthHandler := httptransport.NewServer(
+		makeHealthEndpoint(collector),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			return nil, nil
+		},
+		encodeResponse,
+	)
+	readyHandler := httptransport.NewServer(
+		makeReadyEndpoint(),
+		func(_ context.Context, r *http.Request) (interface{}, error) {
+			return nil, nil
+		},
+		encodeResponse,
+	)
+
+	graphqlHandler := authorizationHandler(newGraphQLHandler(e.validate, e.filter, e.detect, manage(makeWordEndpoint()), manage(makeDisabledEndpoint())))
+	graphqlSchemaHandler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, graphqlSchema)
+	})
+
+	return []route{
+		{"POST", "/validate", validateHandler, apiDoc{
+			Summary:   "Tell if a message contains no blocked words",
+			Params:    []apiParam{messageParam},
+			Responses: []interface{}{validateResponse{}},
+			Errors:    overloaded,
+		}},
+		{"POST", "/filter", filterHandler, apiDoc{
+			Summary:   "Mask blocked words of a message",
+			Params:    []apiParam{messageParam},
+			Responses: []interface{}{filterResponse{}},
+			Errors: map[int]string{
+				http.StatusUnprocessableEntity: "The message contains words of blocked categories in strict mode",
+				http.StatusServiceUnavailable:  overloaded[http.StatusServiceUnavailable],
+				http.StatusGatewayTimeout:      overloaded[http.StatusGatewayTimeout],
+			},
+		}},
+		{"POST", "/check", checkHandler, apiDoc{
+			Summary:   "Validate and filter a message in a single pass",
+			Params:    []apiParam{messageParam},
+			Responses: []interface{}{checkResponse{}},
+			Errors: map[int]string{
+				http.StatusUnprocessableEntity: "The message contains words of blocked categories in strict mode",
+				http.StatusServiceUnavailable:  overloaded[http.StatusServiceUnavailable],
+			},
+		}},
+		{"POST", "/detect", detectHandler, apiDoc{
+			Summary: "Find blocked words of a message with their positions",
+			Params: []apiParam{messageParam, {
+				Name:        "mode",
+				Description: "sentence to split the message into sentences",
+				Enum:        []string{"sentence"},
+			}},
+			Responses: []interface{}{detectResponse{}, sentencesResponse{}},
+			Errors:    overloaded,
+		}},
+		{"POST", "/validate/batch", validateBatchHandler, apiDoc{
+			Summary:   "Validate many messages",
+			Params:    []apiParam{messagesParam},
+			Responses: []interface{}{validateBatchResponse{}},
+			Errors:    overloadedBatch,
+		}},
+		{"POST", "/filter/batch", filterBatchHandler, apiDoc{
+			Summary:   "Filter many messages, rejected ones come with the reasons",
+			Params:    []apiParam{messagesParam},
+			Responses: []interface{}{filterBatchResponse{}},
+			Errors:    overloadedBatch,
+		}},
+		{"POST", "/detect/batch", detectBatchHandler, apiDoc{
+			Summary:   "Detect blocked words of many messages",
+			Params:    []apiParam{messagesParam},
+			Responses: []interface{}{detectBatchResponse{}},
+			Errors:    overloadedBatch,
+		}},
+		{"GET", "/healthz", healthHandler, apiDoc{
+			Summary:   "Health and memory used by the loaded dictionaries",
+			Responses: []interface{}{healthResponse{}},
+		}},
+		{"GET", "/readyz", readyHandler, apiDoc{
+			Summary: "Readiness, 503 until the dictionary is loaded",
+			Errors:  map[int]string{http.StatusServiceUnavailable: "The dictionary is loading"},
+		}},
+		{"POST", "/graphql", graphqlHandler, apiDoc{
+			Summary: "GraphQL queries validate, filter, detect, disabledWords and mutations enableWord, disableWord, see /graphql/schema",
+		}},
+		{"GET", "/graphql", graphqlHandler, apiDoc{
+			Summary: "GraphQL queries given as query, variables and operationName parameters",
+			Params:  []apiParam{{Name: "query", Description: "GraphQL query", Required: true}},
+		}},
+		{"GET", "/graphql/schema", graphqlSchemaHandler, apiDoc{
+			Summary: "GraphQL schema served by /graphql",
+		}},
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/goofansu/wego/client"
+)
+
+func TestDecodeErrors(t *testing.T) {
+	s := newTestServer(t, "-admin.insecure")
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{"POST", "/detect?mode=words", "", `unknown mode \"words\"`},
+		{"POST", "/validate/batch", "message=%zz", "invalid URL escape"},
+		{"POST", "/admin/words/disable", "", "word is required"},
+		{"POST", "/admin/words/enable", "", "word is required"},
+		{"POST", "/admin/test?message=bad", "", "word is required"},
+		{"POST", "/admin/dict/files/disable", "", "file is required"},
+		{"GET", "/admin/stats?n=ten", "", "invalid syntax"},
+		{"GET", "/admin/stats/unused?window=week", "", "invalid duration"},
+		{"POST", "/admin/dict/push", "{", "unexpected EOF"},
+		{"POST", "/admin/words/bulk?dry_run=maybe", "[]", "invalid syntax"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.method == "POST" && tt.path != "/admin/dict/push" && !strings.HasPrefix(tt.path, "/admin/words/bulk") {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		s.api.ServeHTTP(w, r)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s %s = %d %s, want 400 %s", tt.method, tt.path, w.Code, strings.TrimSpace(w.Body.String()), tt.want)
+		}
+	}
+}
+
+// TestClient checks the client package decodes the responses of the routes
+func TestClient(t *testing.T) {
+	loadTestDict(t, "bad")
+	s := newTestServer(t, "-filter.strict")
+	server := httptest.NewServer(s.api)
+	defer server.Close()
+	c, err := client.New(server.URL, client.Retry(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if valid, err := c.Validate(ctx, "so bad"); err != nil || valid {
+		t.Errorf("Validate = %v, %v", valid, err)
+	}
+	if filtered, err := c.Filter(ctx, "fine"); err != nil || filtered != "fine" {
+		t.Errorf("Filter = %q, %v", filtered, err)
+	}
+	if _, err := c.Filter(ctx, "so bad"); err == nil {
+		t.Error("Filter of a blocked message succeeded")
+	} else if blocked, ok := err.(*client.BlockedError); !ok || len(blocked.Reasons) != 1 || blocked.Reasons[0].Word != "bad" {
+		t.Errorf("Filter of a blocked message = %#v", err)
+	}
+	if valid, filtered, err := c.Check(ctx, "fine"); err != nil || !valid || filtered != "fine" {
+		t.Errorf("Check = %v, %q, %v", valid, filtered, err)
+	}
+	if hits, err := c.Detect(ctx, "so bad"); err != nil || len(hits) != 1 || hits[0].Start != 3 || hits[0].RuneEnd != 6 {
+		t.Errorf("Detect = %+v, %v", hits, err)
+	}
+	if v, err := c.ValidateBatch(ctx, []string{"fine", "bad"}); err != nil || !reflect.DeepEqual(v, []bool{true, false}) {
+		t.Errorf("ValidateBatch = %v, %v", v, err)
+	}
+	results, err := c.FilterBatch(ctx, []string{"fine", "bad"})
+	if err != nil || len(results) != 2 || results[0].Text != "fine" || results[1].Blocked == nil {
+		t.Errorf("FilterBatch = %+v, %v", results, err)
+	}
+	if hits, err := c.DetectBatch(ctx, []string{"fine", "bad"}); err != nil || len(hits) != 2 || len(hits[0]) != 0 || len(hits[1]) != 1 {
+		t.Errorf("DetectBatch = %+v, %v", hits, err)
+	}
+
+	// messages rejected for not being utf-8 aren't blocked ones
+	s = newTestServer(t, "-dict.invalid-utf8", "reject")
+	server = httptest.NewServer(s.api)
+	defer server.Close()
+	if c, err = client.New(server.URL, client.Retry(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	results, err = c.FilterBatch(ctx, []string{"\xffbad", "bad"})
+	if err != nil || len(results) != 2 || results[0].Blocked != nil || results[0].Err == nil || results[0].Err.Code != http.StatusBadRequest || results[1].Text != "***" {
+		t.Errorf("FilterBatch = %+v, %v", results, err)
+	}
+}
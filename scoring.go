@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+)
+
+// scorer asks an external moderation service to score messages, the service
+// receives {"text": ..., "hits": [...]} and responds with {"score": 0.87}
+type scorer struct {
+	url       string
+	client    *http.Client
+	severity  int     // max severity of hits considered borderline
+	threshold float64 // messages scored from threshold are blocked
+	allow     bool    // whether messages are allowed when scoring fails
+}
+
+func (s scorer) score(ctx context.Context, text string, hits []dict.Hit) (float64, error) {
+	body, err := json.Marshal(struct {
+		Text string     `json:"text"`
+		Hits []dict.Hit `json:"hits"`
+	}{text, hits})
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("scoring service responded %s", resp.Status)
+	}
+
+	var result struct {
+		Score *float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if result.Score == nil {
+		return 0, fmt.Errorf("scoring service responded without score")
+	}
+	return *result.Score, nil
+}
+
+// borderline tells if hits are all of low severity, messages without hits
+// are never sent to the scoring service
+func (s scorer) borderline(hits []dict.Hit) bool {
+	for _, hit := range hits {
+		if hit.Severity > s.severity {
+			return false
+		}
+	}
+	return len(hits) > 0
+}
+
+// scoringMiddleware scores borderline messages of validate, filter, check
+// and detect requests from the words recorded while serving them, the score
+// is added to the response and decides the verdict of validate, filter and
+// check: messages scored under the threshold are left as they are and no
+// longer violate the dictionary for the middlewares around. Scoring failures
+// are logged and the verdict falls back to the dictionary one, or allowed if
+// allow is set.
+func scoringMiddleware(s scorer, timeout time.Duration, logger log.Logger) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, hits := withHits(ctx)
+			response, err := next(ctx, request)
+			if err != nil || !s.borderline(*hits) {
+				return response, err
+			}
+
+			var text string
+			switch req := request.(type) {
+			case validateRequest:
+				text = req.S
+			case filterRequest:
+				text = req.S
+			case checkRequest:
+				text = req.S
+			case detectRequest:
+				text = req.S
+			default:
+				return response, nil
+			}
+
+			scoreCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			score, err := s.score(scoreCtx, text, *hits)
+			if err != nil {
+				logger.Log("component", "scoring", "err", err)
+				if s.allow {
+					*hits = nil
+					return scored(text, response, nil, true), nil
+				}
+				return response, nil
+			}
+			allowed := score < s.threshold
+			if allowed {
+				*hits = nil
+			}
+			return scored(text, response, &score, allowed), nil
+		}
+	}
+}
+
+// scored adds score to the response to text, which is left as it is if
+// allowed
+func scored(text string, response interface{}, score *float64, allowed bool) interface{} {
+	switch resp := response.(type) {
+	case validateResponse:
+		resp.V = resp.V || allowed
+		resp.Score = score
+		return resp
+	case filterResponse:
+		if allowed {
+			resp.V = text
+		}
+		resp.Score = score
+		return resp
+	case checkResponse:
+		if allowed {
+			resp.Valid, resp.Filtered = true, text
+		}
+		resp.Score = score
+		return resp
+	case detectResponse:
+		resp.Score = score
+		return resp
+	}
+	return response
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestScoring(t *testing.T) {
+	loadTestDict(t, "bad")
+	var scored int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&scored, 1)
+		var req struct {
+			Text string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		score := 0.9
+		if strings.Contains(req.Text, "joke") {
+			score = 0.1
+		}
+		json.NewEncoder(w).Encode(map[string]float64{"score": score})
+	}))
+	defer service.Close()
+	s := newTestServer(t, "-score.url", service.URL)
+
+	tests := []struct {
+		path    string
+		message string
+		want    string
+		scored  bool
+	}{
+		{"/validate", "bad joke", `"result":true,"score":0.1,"verdict":"ok"}`, true},
+		{"/validate", "bad", `"result":false,"score":0.9`, true},
+		{"/validate", "good", `"result":true,"verdict"`, false},
+		{"/filter", "bad joke", `"result":"bad joke","score":0.1`, true},
+		{"/filter", "bad", `"result":"***","score":0.9`, true},
+		{"/check", "bad joke", `"valid":true,"filtered":"bad joke","score":0.1`, true},
+		{"/check", "bad", `"valid":false,"filtered":"***","score":0.9`, true},
+		{"/detect", "bad joke", `"score":0.1`, true},
+	}
+	for _, tt := range tests {
+		atomic.StoreInt32(&scored, 0)
+		form := url.Values{"message": {tt.message}, "user": {"alice"}}
+		r := httptest.NewRequest("POST", tt.path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.api.ServeHTTP(w, r)
+		if !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s %q = %s, want %s", tt.path, tt.message, strings.TrimSpace(w.Body.String()), tt.want)
+		}
+		if got := atomic.LoadInt32(&scored) > 0; got != tt.scored {
+			t.Errorf("%s %q scored = %v, want %v", tt.path, tt.message, got, tt.scored)
+		}
+	}
+}
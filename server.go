@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/stats"
+)
+
+// server holds the handlers of the api and admin listeners, and the
+// endpoints and tenants also served over message queues
+type server struct {
+	api       http.Handler
+	admin     http.Handler // of -admin.addr, nothing is served if it's unset
+	endpoints endpoints
+	tenants   map[string]dict.Replacement
+}
+
+// newServer builds the service, its endpoints and routes as configured by c
+func newServer(c *config, levels *levelLogger, collector *stats.Collector) (*server, error) {
+	var logger log.Logger = levels
+	base, svc, err := newTextService(c, logger, collector)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.httpMessages) > 0 {
+		if err := loadMessages(c.httpMessages); err != nil {
+			return nil, err
+		}
+	}
+	lang, ok := supported(c.httpLang)
+	if !ok {
+		return nil, fmt.Errorf("no messages in language %q", c.httpLang)
+	}
+	defaultLanguage = lang
+
+	trusted, err := parseTrustedProxies(c.trustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	token, err := readToken(c.adminToken)
+	if err != nil {
+		return nil, err
+	}
+	var tenants map[string]dict.Replacement
+	if len(c.filterTenants) > 0 {
+		if tenants, err = loadTenants(c.filterTenants); err != nil {
+			return nil, err
+		}
+	}
+
+	workers := newLimiter(c.limitWorkers, c.limitQueue, c.limitRetryAfter)
+	e, err := makeEndpoints(c, svc, workers, logger)
+	if err != nil {
+		return nil, err
+	}
+	routes := append(apiRoutes(c, e, collector, manageMiddleware(c, token)), adminRoutes(c, base, e, collector, newTuning(workers, levels))...)
+	api, admin, err := routers(c, routes, token, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := recoverer{logger, collector}
+	return &server{
+		api:       recoveringHandler(clientIPHandler(languageHandler(tenantHandler(api, tenants)), trusted), rec),
+		admin:     recoveringHandler(clientIPHandler(admin, trusted), rec),
+		endpoints: e,
+		tenants:   tenants,
+	}, nil
+}
+
+// serveHTTP listens on the addresses of -http.addr and -admin.addr, all
+// addresses of a listener serve the same routes and finish the requests in
+// flight on shutdown
+func serveHTTP(lc *lifecycle, c *config, s *server, logger log.Logger) {
+	for _, l := range []struct {
+		routes  string
+		addrs   string
+		handler http.Handler
+	}{
+		{"api", c.httpAddr, s.api},
+		{"admin", c.adminAddr, s.admin},
+	} {
+		for _, addr := range strings.Split(l.addrs, ",") {
+			if addr = strings.TrimSpace(addr); len(addr) == 0 {
+				continue
+			}
+			logger.Log("transport", "HTTP", "addr", addr, "routes", l.routes)
+			server := &http.Server{Addr: addr, Handler: l.handler}
+			lc.Go("http "+addr, func() error {
+				if err := server.ListenAndServe(); err != http.ErrServerClosed {
+					return err
+				}
+				return nil
+			})
+			lc.OnStop("http "+addr, server.Shutdown)
+		}
+	}
+}
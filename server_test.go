@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/stats"
+)
+
+// newTestServer returns the server configured by the flags of args, it logs
+// nothing
+func newTestServer(t *testing.T, args ...string) *server {
+	t.Helper()
+	fs := flag.NewFlagSet("wego", flag.ContinueOnError)
+	c := newConfig(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	levels, err := newLevelLogger(log.NewNopLogger(), c.logLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := newServer(c, levels, stats.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// loadTestDict replaces the stable dictionary with words
+func loadTestDict(t *testing.T, words ...string) {
+	t.Helper()
+	entries := make([]dict.Entry, len(words))
+	for i, word := range words {
+		entries[i] = dict.Entry{Word: word}
+	}
+	if _, err := dict.Replace(entries); err != nil {
+		t.Fatal(err)
+	}
+}
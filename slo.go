@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+	"github.com/goofansu/wego/stats"
+)
+
+// sloTextServiceMiddleware logs the requests slower than threshold with what
+// helps finding out why, like the length of their text and the number of
+// words found, and counts them by method in /metrics
+type sloTextServiceMiddleware struct {
+	logger    log.Logger
+	threshold time.Duration
+	stats     *stats.Collector
+	next      TextService
+}
+
+// slow logs and counts a request of method for text which took since begin
+// if it's over the threshold, hits is only called then since the number of
+// words found isn't known by every method
+func (mw sloTextServiceMiddleware) slow(ctx context.Context, method, text string, begin time.Time, hits func() int, err error) {
+	took := time.Since(begin)
+	if took < mw.threshold {
+		return
+	}
+	mw.stats.Slow(method)
+
+	version := dict.Version(text)
+	stable, canary := dict.Revisions(ctx)
+	revision := stable
+	if version == dict.Canary {
+		revision = canary
+	}
+	mw.logger.Log(
+		"method", method,
+		"msg", "slow request",
+		"client", clientIPFrom(ctx),
+		"took", took,
+		"threshold", mw.threshold,
+		"bytes", len(text),
+		"runes", utf8.RuneCountInString(text),
+		"hits", hits(),
+		"version", version,
+		"revision", revision,
+		"text", text,
+		"err", err,
+	)
+}
+
+// invalidWords counts the words found in text for methods which don't
+// return them, from the ones recorded by textService if any
+func invalidWords(ctx context.Context, text string) func() int {
+	return func() int {
+		if hits, ok := recordedHits(ctx); ok {
+			return len(hits)
+		}
+		return len(dict.InvalidWords(text))
+	}
+}
+
+func (mw sloTextServiceMiddleware) Validate(ctx context.Context, text string) (bool, error) {
+	begin := time.Now()
+	valid, err := mw.next.Validate(ctx, text)
+	mw.slow(ctx, "validate", text, begin, invalidWords(ctx, text), err)
+	return valid, err
+}
+
+func (mw sloTextServiceMiddleware) Filter(ctx context.Context, text string) (string, error) {
+	begin := time.Now()
+	filtered, err := mw.next.Filter(ctx, text)
+	mw.slow(ctx, "filter", text, begin, invalidWords(ctx, text), err)
+	return filtered, err
+}
+
+func (mw sloTextServiceMiddleware) Check(ctx context.Context, text string) (bool, string, error) {
+	begin := time.Now()
+	valid, filtered, err := mw.next.Check(ctx, text)
+	mw.slow(ctx, "check", text, begin, invalidWords(ctx, text), err)
+	return valid, filtered, err
+}
+
+func (mw sloTextServiceMiddleware) Detect(ctx context.Context, text string) ([]dict.Hit, error) {
+	begin := time.Now()
+	hits, err := mw.next.Detect(ctx, text)
+	mw.slow(ctx, "detect", text, begin, func() int { return len(hits) }, err)
+	return hits, err
+}
+
+func (mw sloTextServiceMiddleware) Sentences(ctx context.Context, text string) ([]dict.Sentence, error) {
+	begin := time.Now()
+	sentences, err := mw.next.Sentences(ctx, text)
+	mw.slow(ctx, "sentences", text, begin, func() int {
+		n := 0
+		for _, s := range sentences {
+			n += len(s.Hits)
+		}
+		return n
+	}, err)
+	return sentences, err
+}
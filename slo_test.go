@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/stats"
+)
+
+func TestSLOMiddleware(t *testing.T) {
+	loadTestDict(t, "bad")
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		call      func(TextService, context.Context) error
+		want      []string
+	}{
+		{"fast", time.Hour, func(svc TextService, ctx context.Context) error {
+			_, err := svc.Validate(ctx, "so bad")
+			return err
+		}, nil},
+		{"validate", time.Nanosecond, func(svc TextService, ctx context.Context) error {
+			_, err := svc.Validate(ctx, "so bad")
+			return err
+		}, []string{"method=validate", `msg="slow request"`, "bytes=6", "runes=6", "hits=1", "version=stable"}},
+		{"check", time.Nanosecond, func(svc TextService, ctx context.Context) error {
+			_, _, err := svc.Check(ctx, "bad, bad 了")
+			return err
+		}, []string{"method=check", "bytes=12", "runes=10", "hits=2"}},
+		{"detect", time.Nanosecond, func(svc TextService, ctx context.Context) error {
+			_, err := svc.Detect(ctx, "all good")
+			return err
+		}, []string{"method=detect", "hits=0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			collector := stats.New()
+			svc := sloTextServiceMiddleware{log.NewLogfmtLogger(&buf), tt.threshold, collector, textService{}}
+			ctx, _ := withHits(context.Background())
+			if err := tt.call(svc, ctx); err != nil {
+				t.Fatal(err)
+			}
+			logged := buf.String()
+			if len(tt.want) == 0 && len(logged) > 0 {
+				t.Errorf("fast request logged %s", logged)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(logged, want) {
+					t.Errorf("logged %s, want %s", strings.TrimSpace(logged), want)
+				}
+			}
+
+			var metrics bytes.Buffer
+			collector.WritePrometheus(&metrics)
+			if slow := strings.Contains(metrics.String(), "wego_slow_requests_total{method="); slow != (len(tt.want) > 0) {
+				t.Errorf("slow request counted %v, want %v", slow, len(tt.want) > 0)
+			}
+		})
+	}
+}
+
+func TestSLOMetrics(t *testing.T) {
+	loadTestDict(t, "bad")
+	s := newTestServer(t, "-admin.insecure", "-slo.latency", "1ns")
+	for _, path := range []string{"/validate", "/validate", "/filter"} {
+		r := httptest.NewRequest("POST", path, strings.NewReader("message=bad"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		s.api.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	w := httptest.NewRecorder()
+	s.api.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", w.Code)
+	}
+	for _, want := range []string{
+		`wego_slow_requests_total{method="filter"} 1`,
+		`wego_slow_requests_total{method="validate"} 2`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("/metrics lacks %s", want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// lengthBuckets group texts by their length in runes, the last one has no
+// upper bound
+var lengthBuckets = []struct {
+	name string
+	max  int
+}{
+	{"0-64", 64},
+	{"64-512", 512},
+	{"512-4k", 4096},
+	{"4k+", 0},
+}
+
+// latencyBounds are the upper bounds in seconds of latency histogram buckets
+var latencyBounds = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+type histogram struct {
+	counts []int64 // by latency bucket, the last one is +Inf
+	sum    float64
+	count  int64
+}
+
+func lengthBucket(runes int) int {
+	for i, b := range lengthBuckets[:len(lengthBuckets)-1] {
+		if runes < b.max {
+			return i
+		}
+	}
+	return len(lengthBuckets) - 1
+}
+
+// Latency records how long a request of endpoint for a text of runes took,
+// histograms are kept by text length since matching cost grows with it
+func (c *Collector) Latency(endpoint string, runes int, took time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hs, ok := c.latency[endpoint]
+	if !ok {
+		hs = make([]histogram, len(lengthBuckets))
+		for i := range hs {
+			hs[i].counts = make([]int64, len(latencyBounds)+1)
+		}
+		c.latency[endpoint] = hs
+	}
+	h := &hs[lengthBucket(runes)]
+	seconds := took.Seconds()
+	h.counts[sort.SearchFloat64s(latencyBounds, seconds)]++
+	h.sum += seconds
+	h.count++
+}
+
+// Slow records a request of endpoint slower than the latency objective
+func (c *Collector) Slow(endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slow[endpoint]++
+}
+
+// Panic records a panic recovered while serving a request
+func (c *Collector) Panic() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.panics++
+}
+
+// Panics returns the number of panics recovered
+func (c *Collector) Panics() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.panics
+}
+
+// WritePrometheus writes the latency histograms, the count of slow requests
+// and the count of panics in the Prometheus text format
+func (c *Collector) WritePrometheus(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	endpoints := make([]string, 0, len(c.latency))
+	for endpoint := range c.latency {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	fmt.Fprintln(w, "# HELP wego_request_duration_seconds Time taken by requests by method and text length in runes.")
+	fmt.Fprintln(w, "# TYPE wego_request_duration_seconds histogram")
+	for _, endpoint := range endpoints {
+		for i, h := range c.latency[endpoint] {
+			labels := fmt.Sprintf(`method=%q,length=%q`, endpoint, lengthBuckets[i].name)
+			var cumulative int64
+			for j, bound := range latencyBounds {
+				cumulative += h.counts[j]
+				fmt.Fprintf(w, "wego_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+			}
+			fmt.Fprintf(w, "wego_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+			fmt.Fprintf(w, "wego_request_duration_seconds_sum{%s} %g\n", labels, h.sum)
+			if _, err := fmt.Fprintf(w, "wego_request_duration_seconds_count{%s} %d\n", labels, h.count); err != nil {
+				return err
+			}
+		}
+	}
+
+	endpoints = endpoints[:0]
+	for endpoint := range c.slow {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	fmt.Fprintln(w, "# HELP wego_slow_requests_total Requests slower than the latency objective by method.")
+	fmt.Fprintln(w, "# TYPE wego_slow_requests_total counter")
+	for _, endpoint := range endpoints {
+		if _, err := fmt.Fprintf(w, "wego_slow_requests_total{method=%q} %d\n", endpoint, c.slow[endpoint]); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP wego_panics_total Panics recovered while serving requests.")
+	fmt.Fprintln(w, "# TYPE wego_panics_total counter")
+	_, err := fmt.Fprintf(w, "wego_panics_total %d\n", c.panics)
+	return err
+}
@@ -0,0 +1,48 @@
+package stats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLengthBucket(t *testing.T) {
+	for runes, want := range map[int]string{0: "0-64", 63: "0-64", 64: "64-512", 511: "64-512", 512: "512-4k", 4095: "512-4k", 4096: "4k+", 1 << 20: "4k+"} {
+		if got := lengthBuckets[lengthBucket(runes)].name; got != want {
+			t.Errorf("lengthBucket(%d) = %s, want %s", runes, got, want)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	c := New()
+	c.Latency("validate", 10, 50*time.Microsecond)
+	c.Latency("validate", 20, 3*time.Millisecond)
+	c.Latency("validate", 10, 10*time.Second)
+	c.Latency("filter", 5000, time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := c.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`wego_request_duration_seconds_bucket{method="validate",length="0-64",le="0.0001"} 1`,
+		`wego_request_duration_seconds_bucket{method="validate",length="0-64",le="0.001"} 1`,
+		`wego_request_duration_seconds_bucket{method="validate",length="0-64",le="0.005"} 2`,
+		`wego_request_duration_seconds_bucket{method="validate",length="0-64",le="5"} 2`,
+		`wego_request_duration_seconds_bucket{method="validate",length="0-64",le="+Inf"} 3`,
+		`wego_request_duration_seconds_count{method="validate",length="0-64"} 3`,
+		`wego_request_duration_seconds_count{method="validate",length="64-512"} 0`,
+		`wego_request_duration_seconds_bucket{method="filter",length="4k+",le="0.001"} 1`,
+		`wego_request_duration_seconds_sum{method="filter",length="4k+"} 0.001`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics lack %s", want)
+		}
+	}
+	// methods are sorted
+	if strings.Index(buf.String(), `method="filter"`) > strings.Index(buf.String(), `method="validate"`) {
+		t.Error("validate histograms written before filter ones")
+	}
+}
@@ -0,0 +1,310 @@
+package stats
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	bucketSize = 10 * time.Second
+	numBuckets = int(time.Hour / bucketSize)
+)
+
+// Windows reported in a snapshot, all of them must fit in numBuckets.
+var windows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+}
+
+// Collector keeps match statistics in memory
+type Collector struct {
+	mu      sync.Mutex
+	since   time.Time
+	words   map[string]*wordStat
+	buckets [numBuckets]bucket
+	latency map[string][]histogram // by endpoint, then length bucket
+	slow    map[string]int64       // requests over the latency objective by endpoint
+	panics  int64                  // recovered while serving requests
+}
+
+type wordStat struct {
+	count int64
+	last  time.Time
+}
+
+type bucket struct {
+	start    int64
+	requests map[string]int64
+	hits     int64
+	versions map[string]*VersionCount
+}
+
+// WordCount is how many times a dictionary word matched, and when it last did
+type WordCount struct {
+	Word  string    `json:"word"`
+	Count int64     `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// VersionCount is the traffic of a dictionary version
+type VersionCount struct {
+	Requests int64   `json:"requests"`
+	Hits     int64   `json:"hits"`
+	HitRate  float64 `json:"hit_rate"`
+}
+
+// Window is the traffic summary of a time window
+type Window struct {
+	Window   string                   `json:"window"`
+	Requests int64                    `json:"requests"`
+	Hits     int64                    `json:"hits"`
+	HitRate  float64                  `json:"hit_rate"`
+	QPS      map[string]float64       `json:"qps"`
+	Versions map[string]*VersionCount `json:"versions"`
+}
+
+// Snapshot is a point in time view of the collector
+type Snapshot struct {
+	Since   time.Time   `json:"since"`
+	Top     []WordCount `json:"top"`
+	Windows []Window    `json:"windows"`
+}
+
+// UnusedReport lists dictionary words not matched within a window
+type UnusedReport struct {
+	Since  time.Time `json:"since"`
+	Window string    `json:"window"`
+	Total  int       `json:"total"`
+	Unused []string  `json:"unused"`
+}
+
+// New returns an empty collector
+func New() *Collector {
+	return &Collector{since: time.Now(), words: make(map[string]*wordStat), latency: make(map[string][]histogram), slow: make(map[string]int64)}
+}
+
+// Request records a call to endpoint matched against a dictionary version,
+// hit tells if the text matched any word
+func (c *Collector) Request(endpoint, version string, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b := c.bucket(time.Now())
+	b.requests[endpoint]++
+	v, ok := b.versions[version]
+	if !ok {
+		v = &VersionCount{}
+		b.versions[version] = v
+	}
+	v.Requests++
+	if hit {
+		b.hits++
+		v.Hits++
+	}
+}
+
+// Match records matched words
+func (c *Collector) Match(words ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, word := range words {
+		ws, ok := c.words[word]
+		if !ok {
+			ws = &wordStat{}
+			c.words[word] = ws
+		}
+		ws.count++
+		ws.last = now
+	}
+}
+
+// Snapshot returns the n most matched words and the traffic of every window,
+// all words are returned when n <= 0
+func (c *Collector) Snapshot(n int) Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	top := make([]WordCount, 0, len(c.words))
+	for word, ws := range c.words {
+		top = append(top, WordCount{word, ws.count, ws.last})
+	}
+	sort.Sort(byCount(top))
+	if n > 0 && len(top) > n {
+		top = top[:n]
+	}
+
+	now := time.Now()
+	current := now.Truncate(bucketSize).Unix()
+	ws := make([]Window, 0, len(windows))
+	for _, window := range windows {
+		w := Window{
+			Window:   window.name,
+			QPS:      make(map[string]float64),
+			Versions: make(map[string]*VersionCount),
+		}
+		oldest := current - int64(window.duration/time.Second) + int64(bucketSize/time.Second)
+		for i := range c.buckets {
+			b := &c.buckets[i]
+			if b.requests == nil || b.start < oldest || b.start > current {
+				continue
+			}
+			for endpoint, count := range b.requests {
+				w.Requests += count
+				w.QPS[endpoint] += float64(count)
+			}
+			w.Hits += b.hits
+			for version, count := range b.versions {
+				v, ok := w.Versions[version]
+				if !ok {
+					v = &VersionCount{}
+					w.Versions[version] = v
+				}
+				v.Requests += count.Requests
+				v.Hits += count.Hits
+			}
+		}
+
+		seconds := window.duration.Seconds()
+		if elapsed := now.Sub(c.since).Seconds(); elapsed < seconds {
+			seconds = elapsed
+		}
+		for endpoint := range w.QPS {
+			w.QPS[endpoint] /= seconds
+		}
+		if w.Requests > 0 {
+			w.HitRate = float64(w.Hits) / float64(w.Requests)
+		}
+		for _, v := range w.Versions {
+			v.HitRate = float64(v.Hits) / float64(v.Requests)
+		}
+		ws = append(ws, w)
+	}
+
+	return Snapshot{Since: c.since, Top: top, Windows: ws}
+}
+
+// Unused returns the words of dictionary which have not matched within
+// window, words never matched since the collector started, or since the
+// statistics it restored were collected, are included
+func (c *Collector) Unused(dictionary []string, window time.Duration) UnusedReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deadline := time.Now().Add(-window)
+	unused := []string{}
+	for _, word := range dictionary {
+		if ws, ok := c.words[word]; !ok || ws.last.Before(deadline) {
+			unused = append(unused, word)
+		}
+	}
+	sort.Strings(unused)
+
+	return UnusedReport{
+		Since:  c.since,
+		Window: window.String(),
+		Total:  len(dictionary),
+		Unused: unused,
+	}
+}
+
+// Flush writes a snapshot with all words to path
+func (c *Collector) Flush(path string) error {
+	data, err := json.MarshalIndent(c.Snapshot(0), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Restore reads the words flushed to path by a previous process, so they are
+// not reported as unused after a restart, and collects since it did. A
+// missing file is not an error.
+func (c *Collector) Restore(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !snapshot.Since.IsZero() && snapshot.Since.Before(c.since) {
+		c.since = snapshot.Since
+	}
+	for _, wc := range snapshot.Top {
+		ws, ok := c.words[wc.Word]
+		if !ok {
+			ws = &wordStat{}
+			c.words[wc.Word] = ws
+		}
+		ws.count += wc.Count
+		if wc.Last.After(ws.last) {
+			ws.last = wc.Last
+		}
+	}
+	return nil
+}
+
+// FlushEvery flushes to path every interval until stop is closed, errors are
+// passed to onError
+func (c *Collector) FlushEvery(path string, interval time.Duration, stop <-chan struct{}, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(path); err != nil {
+				onError(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// bucket returns the bucket of t, recycling it when it holds older data
+func (c *Collector) bucket(t time.Time) *bucket {
+	start := t.Truncate(bucketSize).Unix()
+	b := &c.buckets[int(start/int64(bucketSize/time.Second))%numBuckets]
+	if b.start != start || b.requests == nil {
+		b.start = start
+		b.requests = make(map[string]int64)
+		b.hits = 0
+		b.versions = make(map[string]*VersionCount)
+	}
+	return b
+}
+
+type byCount []WordCount
+
+func (s byCount) Len() int      { return len(s) }
+func (s byCount) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byCount) Less(i, j int) bool {
+	if s[i].Count != s[j].Count {
+		return s[i].Count > s[j].Count
+	}
+	return s[i].Word < s[j].Word
+}
@@ -0,0 +1,51 @@
+package stats
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUnused(t *testing.T) {
+	c := New()
+	c.Match("bad", "worse")
+	c.words["worse"].last = time.Now().Add(-2 * time.Hour)
+
+	report := c.Unused([]string{"worse", "bad", "never"}, time.Hour)
+	if want := []string{"never", "worse"}; !reflect.DeepEqual(report.Unused, want) || report.Total != 3 {
+		t.Errorf("Unused = %v of %d, want %v of 3", report.Unused, report.Total, want)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := New().Restore(path); err != nil {
+		t.Fatalf("Restore(missing) = %v", err)
+	}
+
+	before := New()
+	before.since = time.Now().Add(-24 * time.Hour)
+	before.Match("bad", "bad", "old")
+	before.words["old"].last = time.Now().Add(-2 * time.Hour)
+	if err := before.Flush(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// words matched before the restart are not unused
+	after := New()
+	after.Match("bad")
+	if err := after.Restore(path); err != nil {
+		t.Fatal(err)
+	}
+	report := after.Unused([]string{"bad", "old", "never"}, time.Hour)
+	if want := []string{"never", "old"}; !reflect.DeepEqual(report.Unused, want) {
+		t.Errorf("Unused = %v, want %v", report.Unused, want)
+	}
+	if !report.Since.Equal(before.since) {
+		t.Errorf("Since = %v, want %v", report.Since, before.since)
+	}
+	if top := after.Snapshot(1).Top; top[0].Word != "bad" || top[0].Count != 3 {
+		t.Errorf("Top = %v, want bad matched 3 times", top)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+)
+
+// pushRequest carries the entries of a dictionary pushed by wego sync
+type pushRequest struct {
+	Entries []dict.Entry
+}
+
+type pushResponse struct {
+	Revision string `json:"revision"`
+	Entries  int    `json:"entries"`
+}
+
+// makePushEndpoint replaces the stable dictionary with the pushed one
+func makePushEndpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(pushRequest)
+		d, err := dict.Replace(req.Entries)
+		if err != nil {
+			return nil, err
+		}
+		return pushResponse{d.Revision(), d.Len()}, nil
+	}
+}
+
+func decodePushRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req pushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req.Entries); err != nil {
+		return nil, bodyError(err)
+	}
+	return req, nil
+}
+
+// syncer pushes the dictionary read from source to instances whenever it
+// changes, in stages: the instances of a stage must be healthy with the new
+// revision after soak before the next stage is pushed, otherwise the
+// dictionary every instance ran before is pushed back to it and the rollout
+// is given up. Instances reloading their dictionary with -dict.refresh are
+// refused, a reload would replace the pushed dictionary.
+type syncer struct {
+	source    string
+	git       string // repository pulled before reading source
+	instances []string
+	stages    []int // cumulative number of instances
+	soak      time.Duration
+	client    *http.Client
+	token     string // bearer token of the admin routes of instances
+	logger    log.Logger
+
+	read     []dict.Entry // last read from source
+	revision string       // of the last rollout, successful or not
+}
+
+// runSync is the wego sync command, it returns the exit code
+func runSync(args []string) int {
+	fs := flag.NewFlagSet("wego sync", flag.ExitOnError)
+	var (
+		source    = fs.String("source", "*.txt", "Dictionary to distribute, glob pattern, http(s), s3:// or gs:// url like -dict.path")
+		git       = fs.String("git", "", "Git repository pulled before reading the source, for sources within a checkout")
+		instances = fs.String("instances", "", "Comma separated base urls of the wego instances, e.g. http://10.0.0.1:8000, their -admin.addr if set")
+		token     = fs.String("token", "", "File holding the bearer token of the instances, like -admin.token")
+		interval  = fs.Duration("interval", time.Minute, "Interval between checks of the source")
+		stages    = fs.String("stages", "1,100%", "Comma separated cumulative sizes of the rollout stages, numbers of instances or percentages")
+		soak      = fs.Duration("soak", 30*time.Second, "Time the instances of a stage run the new dictionary before their health is checked")
+		timeout   = fs.Duration("timeout", 10*time.Second, "Timeout of requests to instances")
+		once      = fs.Bool("once", false, "Sync once and exit, with status 1 if the rollout failed")
+		proxy     = fs.String("proxy", "", "Proxy of remote sources like -dict.proxy, HTTP_PROXY and HTTPS_PROXY are used if empty")
+	)
+	fs.Parse(args)
+
+	var logger log.Logger
+	logger = log.NewLogfmtLogger(os.Stderr)
+	logger = log.With(logger, "component", "sync")
+
+	if len(*proxy) > 0 {
+		if err := dict.SetProxy(*proxy); err != nil {
+			logger.Log("err", err)
+			return 2
+		}
+	}
+
+	adminToken, err := readToken(*token)
+	if err != nil {
+		logger.Log("err", err)
+		return 2
+	}
+	s := &syncer{
+		source: *source,
+		git:    *git,
+		soak:   *soak,
+		client: &http.Client{Timeout: *timeout},
+		token:  adminToken,
+		logger: logger,
+	}
+	for _, instance := range strings.Split(*instances, ",") {
+		if instance = strings.TrimRight(strings.TrimSpace(instance), "/"); len(instance) > 0 {
+			s.instances = append(s.instances, instance)
+		}
+	}
+	if len(s.instances) == 0 {
+		logger.Log("err", "no instances")
+		return 2
+	}
+	if s.stages, err = parseStages(*stages, len(s.instances)); err != nil {
+		logger.Log("err", err)
+		return 2
+	}
+
+	for {
+		err := s.sync()
+		if err != nil {
+			logger.Log("err", err)
+		}
+		if *once {
+			if err != nil {
+				return 1
+			}
+			return 0
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// parseStages parses stages into cumulative numbers of instances, the last
+// stage always covers all of them
+func parseStages(s string, instances int) ([]int, error) {
+	var stages []int
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		var n int
+		if strings.HasSuffix(field, "%") {
+			percent, err := strconv.ParseFloat(strings.TrimSuffix(field, "%"), 64)
+			if err != nil || percent <= 0 || percent > 100 {
+				return nil, fmt.Errorf("invalid stage %q", field)
+			}
+			n = int(math.Ceil(percent * float64(instances) / 100))
+		} else {
+			var err error
+			if n, err = strconv.Atoi(field); err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid stage %q", field)
+			}
+		}
+		if n > instances {
+			n = instances
+		}
+		if len(stages) == 0 || n > stages[len(stages)-1] {
+			stages = append(stages, n)
+		}
+	}
+	if len(stages) == 0 || stages[len(stages)-1] < instances {
+		stages = append(stages, instances)
+	}
+	return stages, nil
+}
+
+// sync rolls out the source if it has changed since the last rollout
+func (s *syncer) sync() error {
+	if len(s.git) > 0 {
+		if out, err := exec.Command("git", "-C", s.git, "pull", "--ff-only").CombinedOutput(); err != nil {
+			return fmt.Errorf("git pull: %v: %s", err, bytes.TrimSpace(out))
+		}
+	}
+	entries, err := dict.Read(s.source)
+	if err == dict.ErrNotModified {
+		entries, err = s.read, nil
+	}
+	if err != nil {
+		return err
+	}
+	s.read = entries
+	revision := dict.NewDictionary(entries).Revision()
+	if revision == s.revision {
+		return nil
+	}
+	for _, instance := range s.instances {
+		if err := s.checkRefresh(instance); err != nil {
+			return fmt.Errorf("%s: %v", instance, err)
+		}
+	}
+	s.revision = revision
+
+	s.logger.Log("msg", "rollout", "revision", revision, "entries", len(entries))
+	previous := make(map[string][]dict.Entry, len(s.instances))
+	done := 0
+	for _, end := range s.stages {
+		stage := s.instances[done:end]
+		pushed := make(map[string]string, len(stage))
+		for _, instance := range stage {
+			before, err := s.entries(instance)
+			if err == nil {
+				previous[instance] = before
+				pushed[instance], err = s.push(instance, entries)
+			}
+			if err != nil {
+				// the instance may be restarting, the rollout is retried
+				s.revision = ""
+				return s.rollback(previous, fmt.Errorf("push to %s: %v", instance, err))
+			}
+		}
+		time.Sleep(s.soak)
+		for _, instance := range stage {
+			if err := s.check(instance, pushed[instance]); err != nil {
+				return s.rollback(previous, fmt.Errorf("health of %s: %v", instance, err))
+			}
+		}
+		s.logger.Log("msg", "stage done", "revision", revision, "instances", end)
+		done = end
+	}
+	return nil
+}
+
+// rollback pushes back the dictionaries the instances ran before the
+// rollout, a revision unhealthy instances ran isn't rolled out again until
+// the source changes
+func (s *syncer) rollback(previous map[string][]dict.Entry, cause error) error {
+	for instance, entries := range previous {
+		if _, err := s.push(instance, entries); err != nil {
+			s.logger.Log("msg", "rollback failed", "instance", instance, "err", err)
+		}
+	}
+	return fmt.Errorf("rollout rolled back: %v", cause)
+}
+
+// get decodes the json response to a GET of path on instance into v
+func (s *syncer) get(instance, path string, v interface{}) error {
+	req, err := http.NewRequest("GET", instance+path, nil)
+	if err != nil {
+		return err
+	}
+	if len(s.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// checkRefresh fails if instance reloads its dictionary
+func (s *syncer) checkRefresh(instance string) error {
+	var d dictResponse
+	if err := s.get(instance, "/admin/dict", &d); err != nil {
+		return err
+	}
+	if len(d.Refresh) > 0 {
+		return fmt.Errorf("reloads its dictionary every %s, which would replace the pushed one, remove -dict.refresh", d.Refresh)
+	}
+	return nil
+}
+
+// entries returns the entries of the stable dictionary of instance
+func (s *syncer) entries(instance string) ([]dict.Entry, error) {
+	var entries []dict.Entry
+	return entries, s.get(instance, "/admin/dict/entries", &entries)
+}
+
+// push sends entries to instance and returns the revision it runs then
+func (s *syncer) push(instance string, entries []dict.Entry) (string, error) {
+	body, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", instance+"/admin/dict/push", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+
+	var pushed pushResponse
+	if err := json.Unmarshal(data, &pushed); err != nil {
+		return "", err
+	}
+	return pushed.Revision, nil
+}
+
+// check tells if instance is healthy and still runs revision
+func (s *syncer) check(instance, revision string) error {
+	resp, err := s.client.Get(instance + "/healthz")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+
+	var health healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return err
+	}
+	if health.Status != "ok" {
+		return fmt.Errorf("status %q", health.Status)
+	}
+	for _, d := range health.Dictionaries {
+		if d.Version == dict.Stable && d.Revision != revision {
+			return fmt.Errorf("runs revision %s instead of %s", d.Revision, revision)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+)
+
+func TestParseStages(t *testing.T) {
+	tests := []struct {
+		s         string
+		instances int
+		want      []int
+		invalid   bool
+	}{
+		{"", 4, []int{4}, false},
+		{"1", 4, []int{1, 4}, false},
+		{"1,50%", 4, []int{1, 2, 4}, false},
+		{"10%,10%,100%", 5, []int{1, 5}, false},
+		{"9", 4, []int{4}, false},
+		{"0", 4, nil, true},
+		{"150%", 4, nil, true},
+		{"half", 4, nil, true},
+	}
+	for _, tt := range tests {
+		stages, err := parseStages(tt.s, tt.instances)
+		if (err != nil) != tt.invalid || !reflect.DeepEqual(stages, tt.want) {
+			t.Errorf("parseStages(%q, %d) = %v, %v, want %v", tt.s, tt.instances, stages, err, tt.want)
+		}
+	}
+}
+
+// syncInstance serves the admin routes wego sync uses, the revision it runs
+// is the one of the entries pushed to it, or broken if it's unhealthy
+type syncInstance struct {
+	mu      sync.Mutex
+	entries []dict.Entry
+	pushes  int
+	refresh string
+	broken  string // revision the instance fails to run
+}
+
+func (i *syncInstance) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	revision := dict.NewDictionary(i.entries).Revision()
+	switch r.URL.Path {
+	case "/admin/dict":
+		json.NewEncoder(w).Encode(dictResponse{Revision: revision, Refresh: i.refresh})
+	case "/admin/dict/entries":
+		json.NewEncoder(w).Encode(i.entries)
+	case "/admin/dict/push":
+		i.entries = nil
+		json.NewDecoder(r.Body).Decode(&i.entries)
+		i.pushes++
+		revision = dict.NewDictionary(i.entries).Revision()
+		json.NewEncoder(w).Encode(pushResponse{revision, len(i.entries)})
+	case "/healthz":
+		status := "ok"
+		if revision == i.broken {
+			status = "loading"
+		}
+		json.NewEncoder(w).Encode(healthResponse{Status: status, Dictionaries: []dict.Footprint{{Version: dict.Stable, Revision: revision}}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (i *syncInstance) words() []string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var words []string
+	for _, e := range i.entries {
+		words = append(words, e.Word)
+	}
+	return words
+}
+
+func (i *syncInstance) pushCount() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.pushes
+}
+
+func TestSync(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "words.txt")
+	write := func(words ...string) {
+		t.Helper()
+		if err := ioutil.WriteFile(source, []byte(strings.Join(words, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	instances := []*syncInstance{{}, {}, {}}
+	var urls []string
+	for _, i := range instances {
+		server := httptest.NewServer(i)
+		defer server.Close()
+		urls = append(urls, server.URL)
+	}
+	s := &syncer{
+		source:    source,
+		instances: urls,
+		stages:    []int{1, 3},
+		client:    http.DefaultClient,
+		logger:    log.NewNopLogger(),
+	}
+
+	write("bad")
+	if err := s.sync(); err != nil {
+		t.Fatal(err)
+	}
+	for n, i := range instances {
+		if words := i.words(); !reflect.DeepEqual(words, []string{"bad"}) {
+			t.Errorf("instance %d runs %q, want [bad]", n, words)
+		}
+	}
+
+	// an instance unhealthy with the new revision rolls every pushed one back
+	write("bad", "worse")
+	entries, err := dict.Read(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances[2].mu.Lock()
+	instances[2].broken = dict.NewDictionary(entries).Revision()
+	instances[2].mu.Unlock()
+	if err := s.sync(); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("sync with an unhealthy instance = %v, want rolled back", err)
+	}
+	for n, i := range instances {
+		if words := i.words(); !reflect.DeepEqual(words, []string{"bad"}) {
+			t.Errorf("instance %d runs %q after the rollback, want [bad]", n, words)
+		}
+	}
+
+	// the unhealthy revision isn't rolled out again until the source changes
+	pushes := instances[0].pushCount()
+	if err := s.sync(); err != nil {
+		t.Fatal(err)
+	}
+	if instances[0].pushCount() != pushes {
+		t.Error("revision rolled back is rolled out again")
+	}
+
+	// instances reloading their dictionary are refused before any push
+	write("bad", "worst")
+	instances[1].mu.Lock()
+	instances[1].refresh = "1m0s"
+	instances[1].mu.Unlock()
+	if err := s.sync(); err == nil || !strings.Contains(err.Error(), "-dict.refresh") {
+		t.Errorf("sync to an instance reloading its dictionary = %v", err)
+	}
+	if instances[0].pushCount() != pushes {
+		t.Error("pushed to instances while one reloads its dictionary")
+	}
+}
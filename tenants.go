@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/goofansu/wego/dict"
+)
+
+// tenantConfig is the configuration of a tenant in the -filter.tenants file
+type tenantConfig struct {
+	Mask string `json:"mask"` // single character
+	Text string `json:"text"`
+}
+
+// loadTenants reads the replacements of tenants from file, a json object of
+// configurations by tenant
+func loadTenants(file string) (map[string]dict.Replacement, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var configs map[string]tenantConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, err
+	}
+
+	tenants := make(map[string]dict.Replacement, len(configs))
+	for tenant, c := range configs {
+		r := dict.Replacement{Text: c.Text}
+		if len(c.Mask) > 0 {
+			if utf8.RuneCountInString(c.Mask) != 1 {
+				return nil, fmt.Errorf("mask %q of tenant %q must be a single character", c.Mask, tenant)
+			}
+			r.Mask, _ = utf8.DecodeRuneInString(c.Mask)
+		}
+		tenants[tenant] = r
+	}
+	return tenants, nil
+}
+
+// tenantHandler puts the replacement of the tenant named by the X-Tenant
+// header or the tenant query parameter into the context of requests, others
+// get the default one
+func tenantHandler(next http.Handler, tenants map[string]dict.Replacement) http.Handler {
+	if len(tenants) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get("X-Tenant")
+		if len(tenant) == 0 {
+			tenant = r.URL.Query().Get("tenant")
+		}
+		if repl, ok := tenants[tenant]; ok {
+			r = r.WithContext(dict.ContextWithReplacement(r.Context(), repl))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
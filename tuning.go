@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/log"
+	"github.com/goofansu/wego/dict"
+)
+
+// Log levels, error only logs entries with a non nil err
+var logLevels = []string{"info", "error", "off"}
+
+// levelLogger drops the entries below its level, which can be changed while
+// serving
+type levelLogger struct {
+	next  log.Logger
+	level int32 // index in logLevels
+}
+
+func newLevelLogger(next log.Logger, level string) (*levelLogger, error) {
+	l := &levelLogger{next: next}
+	return l, l.SetLevel(level)
+}
+
+func (l *levelLogger) Level() string {
+	return logLevels[atomic.LoadInt32(&l.level)]
+}
+
+func (l *levelLogger) SetLevel(level string) error {
+	i, err := levelIndex(level)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&l.level, i)
+	return nil
+}
+
+func levelIndex(level string) (int32, error) {
+	for i, name := range logLevels {
+		if name == level {
+			return int32(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", level)
+}
+
+func (l *levelLogger) Log(keyvals ...interface{}) error {
+	switch l.Level() {
+	case "off":
+		return nil
+	case "error":
+		failed := false
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			if keyvals[i] == "err" && keyvals[i+1] != nil {
+				failed = true
+				break
+			}
+		}
+		if !failed {
+			return nil
+		}
+	}
+	return l.next.Log(keyvals...)
+}
+
+// tuning are the runtime knobs changed by /admin/runtime
+type tuning struct {
+	mu        *sync.Mutex // serializes changes
+	gcPercent *int        // as set last, the runtime can only tell it by setting it
+	limiter   *limiter
+	logger    *levelLogger
+}
+
+func newTuning(limiter *limiter, logger *levelLogger) tuning {
+	gcPercent := gcPercentOf(os.Getenv("GOGC"))
+	return tuning{new(sync.Mutex), &gcPercent, limiter, logger}
+}
+
+// gcPercentOf returns the gc percent set by the GOGC environment variable
+// gogc, like the runtime reads it
+func gcPercentOf(gogc string) int {
+	if strings.EqualFold(gogc, "off") {
+		return -1
+	}
+	if n, err := strconv.Atoi(gogc); err == nil {
+		return n
+	}
+	return 100
+}
+
+type runtimeRequest struct {
+	GCPercent *int
+	Workers   *int
+	Queue     *int
+	CacheSize *int
+	LogLevel  *string
+}
+
+type runtimeResponse struct {
+	GCPercent int    `json:"gc_percent"`
+	Workers   int    `json:"workers"`
+	Queue     int    `json:"queue"`
+	CacheSize int    `json:"cache_size"`
+	LogLevel  string `json:"log_level"`
+}
+
+// makeRuntimeEndpoint applies the knobs given in the request and returns
+// them all, nothing is changed if one is invalid
+func makeRuntimeEndpoint(t tuning) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(runtimeRequest)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		workers, queue := t.limiter.Size()
+		if req.Workers != nil {
+			workers = *req.Workers
+		}
+		if req.Queue != nil {
+			queue = *req.Queue
+		}
+		if workers < 0 {
+			return nil, badRequestError{fmt.Errorf("invalid workers %d", workers)}
+		}
+		if queue < 0 {
+			return nil, badRequestError{fmt.Errorf("invalid queue %d", queue)}
+		}
+		if req.CacheSize != nil && *req.CacheSize < 0 {
+			return nil, badRequestError{fmt.Errorf("invalid cache size %d", *req.CacheSize)}
+		}
+		if req.LogLevel != nil {
+			if _, err := levelIndex(*req.LogLevel); err != nil {
+				return nil, badRequestError{err}
+			}
+		}
+
+		t.limiter.Resize(workers, queue)
+		if req.CacheSize != nil {
+			dict.SetCacheSize(*req.CacheSize)
+		}
+		if req.LogLevel != nil {
+			t.logger.SetLevel(*req.LogLevel)
+		}
+		if req.GCPercent != nil {
+			debug.SetGCPercent(*req.GCPercent)
+			*t.gcPercent = *req.GCPercent
+		}
+		return runtimeResponse{*t.gcPercent, workers, queue, dict.CacheSize(), t.logger.Level()}, nil
+	}
+}
+
+func decodeRuntimeRequest(_ context.Context, r *http.Request) (interface{}, error) {
+	var req runtimeRequest
+	if r.Method != http.MethodPost {
+		return req, nil
+	}
+	ints := []struct {
+		name string
+		dst  **int
+	}{{"gc_percent", &req.GCPercent}, {"workers", &req.Workers}, {"queue", &req.Queue}, {"cache_size", &req.CacheSize}}
+	for _, p := range ints {
+		if s := r.FormValue(p.name); len(s) > 0 {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, badRequestError{fmt.Errorf("invalid %s %q", p.name, s)}
+			}
+			*p.dst = &n
+		}
+	}
+	if s := r.FormValue("log_level"); len(s) > 0 {
+		req.LogLevel = &s
+	}
+	return req, nil
+}
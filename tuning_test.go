@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRuntime(t *testing.T) {
+	s := newTestServer(t, "-admin.insecure", "-limit.workers", "4", "-limit.queue", "8")
+	post := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/admin/runtime", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.api.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"workers=-1", "invalid workers -1"},
+		{"queue=-1", "invalid queue -1"},
+		{"cache_size=-1", "invalid cache size -1"},
+		{"log_level=loud", `unknown log level \"loud\"`},
+		{"workers=many", `invalid workers \"many\"`},
+		{"workers=2&queue=-1", "invalid queue -1"},
+	}
+	for _, tt := range tests {
+		w := post(tt.body)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), tt.want) {
+			t.Errorf("%s = %d %s, want 400 %s", tt.body, w.Code, strings.TrimSpace(w.Body.String()), tt.want)
+		}
+	}
+
+	w := post("workers=2&log_level=error")
+	var resp runtimeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || resp.Workers != 2 || resp.Queue != 8 || resp.LogLevel != "error" {
+		t.Errorf("workers=2&log_level=error = %d %+v, want 200 with 2 workers, queue 8 and level error", w.Code, resp)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/goofansu/wego/dict"
+)
+
+// Policies for messages which are not valid utf-8, see -dict.invalid-utf8
+const (
+	sanitizeUTF8 = "sanitize"
+	rejectUTF8   = "reject"
+)
+
+func checkUTF8Policy(policy string) error {
+	if policy != sanitizeUTF8 && policy != rejectUTF8 {
+		return fmt.Errorf("unknown invalid utf-8 policy %q", policy)
+	}
+	return nil
+}
+
+// invalidUTF8Error is returned for messages which are not valid utf-8 when
+// they are rejected, it's encoded as 400
+type invalidUTF8Error struct{}
+
+func (e invalidUTF8Error) Error() string {
+	return "message is not valid utf-8"
+}
+
+func (e invalidUTF8Error) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e invalidUTF8Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"error": e.Error()})
+}
+
+// utf8TextServiceMiddleware sanitizes messages which are not valid utf-8
+// with dict.Sanitize before they are matched, or rejects them
+type utf8TextServiceMiddleware struct {
+	reject bool
+	next   TextService
+}
+
+func (mw utf8TextServiceMiddleware) text(s string) (string, error) {
+	if utf8.ValidString(s) {
+		return s, nil
+	}
+	if mw.reject {
+		return "", invalidUTF8Error{}
+	}
+	return dict.Sanitize(s), nil
+}
+
+func (mw utf8TextServiceMiddleware) Validate(ctx context.Context, text string) (bool, error) {
+	text, err := mw.text(text)
+	if err != nil {
+		return false, err
+	}
+	return mw.next.Validate(ctx, text)
+}
+
+func (mw utf8TextServiceMiddleware) Filter(ctx context.Context, text string) (string, error) {
+	text, err := mw.text(text)
+	if err != nil {
+		return "", err
+	}
+	return mw.next.Filter(ctx, text)
+}
+
+func (mw utf8TextServiceMiddleware) Check(ctx context.Context, text string) (bool, string, error) {
+	text, err := mw.text(text)
+	if err != nil {
+		return false, "", err
+	}
+	return mw.next.Check(ctx, text)
+}
+
+func (mw utf8TextServiceMiddleware) Detect(ctx context.Context, text string) ([]dict.Hit, error) {
+	text, err := mw.text(text)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.Detect(ctx, text)
+}
+
+func (mw utf8TextServiceMiddleware) Sentences(ctx context.Context, text string) ([]dict.Sentence, error) {
+	text, err := mw.text(text)
+	if err != nil {
+		return nil, err
+	}
+	return mw.next.Sentences(ctx, text)
+}